@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/spf13/pflag"
+)
+
+var (
+	archiveFile = path.Join(cookieDir, ".bilibili_archive.txt")
+	force       bool
+	// downloadArchive is nil when the archive is disabled.
+	downloadArchive *archive
+)
+
+// archive records the downloaded streams, every line is "aid\tcid\tqn\tpath".
+type archive struct {
+	mu      sync.Mutex
+	file    string
+	entries map[string]string
+}
+
+func archiveKey(aid int, cid int64, qn bilibili.Qn) string {
+	return fmt.Sprintf("%d\t%d\t%d", aid, cid, qn)
+}
+
+func loadArchive(file string) (*archive, error) {
+	a := &archive{file: file, entries: make(map[string]string)}
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return a, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "\t", 4)
+		if len(fields) < 3 {
+			continue
+		}
+		location := ""
+		if len(fields) == 4 {
+			location = fields[3]
+		}
+		a.entries[strings.Join(fields[:3], "\t")] = location
+	}
+	return a, scanner.Err()
+}
+
+// Lookup returns where the stream was downloaded to.
+func (a *archive) Lookup(aid int, cid int64, qn bilibili.Qn) (string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	location, ok := a.entries[archiveKey(aid, cid, qn)]
+	return location, ok
+}
+
+func (a *archive) Add(aid int, cid int64, qn bilibili.Qn, location string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := archiveKey(aid, cid, qn)
+	if old, ok := a.entries[key]; ok && old == location {
+		return nil
+	}
+	f, err := os.OpenFile(a.file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err = fmt.Fprintf(f, "%s\t%s\n", key, location); err != nil {
+		return err
+	}
+	a.entries[key] = location
+	return nil
+}
+
+func addArchiveFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(&archiveFile, "archive", archiveFile, "The download archive recording the downloaded videos, empty disables it")
+	flagSet.BoolVar(&force, "force", false, "Download the videos recorded in the download archive again")
+}
+
+func initArchive() error {
+	if len(archiveFile) == 0 {
+		return nil
+	}
+	var err error
+	downloadArchive, err = loadArchive(archiveFile)
+	return err
+}
+
+// archived reports whether the stream is downloaded already and should be skipped, --force downloads it again.
+func archived(aid int, cid int64, qn bilibili.Qn) (string, bool) {
+	location, ok := lookupArchived(aid, cid, qn)
+	if ok {
+		fmt.Printf("Skip av%d (cid %d, %s), it is downloaded to %s already, use --force to download it again.\n", aid, cid, qn, location)
+	}
+	return location, ok
+}
+
+// lookupArchived is archived without the message.
+func lookupArchived(aid int, cid int64, qn bilibili.Qn) (string, bool) {
+	if downloadArchive == nil || force {
+		return "", false
+	}
+	return downloadArchive.Lookup(aid, cid, qn)
+}
+
+func recordArchive(aid int, cid int64, qn bilibili.Qn, location string) {
+	if downloadArchive == nil {
+		return
+	}
+	if err := downloadArchive.Add(aid, cid, qn, location); err != nil {
+		fmt.Printf("Record %s into the download archive failed: %v\n", location, err)
+	}
+}
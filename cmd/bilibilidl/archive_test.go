@@ -0,0 +1,54 @@
+package main
+
+import (
+	"path"
+	"testing"
+
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+)
+
+func TestArchive(t *testing.T) {
+	file := path.Join(t.TempDir(), "archive.txt")
+	a, err := loadArchive(file)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err = a.Add(715024588, 323723441, bilibili.Qn1080P, "videos/a b.mp4"); err != nil {
+		t.Error(err)
+		return
+	}
+	a, err = loadArchive(file)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	location, ok := a.Lookup(715024588, 323723441, bilibili.Qn1080P)
+	if !ok || location != "videos/a b.mp4" {
+		t.Errorf("unexpected archive entry %q %v", location, ok)
+	}
+	if _, ok = a.Lookup(715024588, 323723441, bilibili.Qn4k); ok {
+		t.Error("unexpected archive entry of another quality")
+	}
+}
+
+func TestPagesArchived(t *testing.T) {
+	a, err := loadArchive(path.Join(t.TempDir(), "archive.txt"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	downloadArchive = a
+	defer func() { downloadArchive = nil }()
+
+	info := &VideoInfo{AID: 715024588, Pages: []Page{{CID: 323723441}, {CID: 323723442}}}
+	recordArchive(info.AID, 323723441, bilibili.Qn1080P, "videos/pages.mp4")
+	if _, ok := pagesArchived(info, bilibili.Qn1080P); ok {
+		t.Error("unexpected archived pages with a page missing")
+	}
+	recordArchive(info.AID, 323723442, bilibili.Qn1080P, "videos/pages.mp4")
+	location, ok := pagesArchived(info, bilibili.Qn1080P)
+	if !ok || location != "videos/pages.mp4" {
+		t.Errorf("unexpected archived pages %q %v", location, ok)
+	}
+}
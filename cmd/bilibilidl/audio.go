@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/misssonder/bilibili/internal/filename"
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/misssonder/bilibili/pkg/errors"
+	"github.com/misssonder/bilibili/pkg/mp4"
+	"github.com/misssonder/bilibili/pkg/progress"
+)
+
+var (
+	audioOnly   bool
+	audioFormat string
+)
+
+// mediaTags are the metadata written into the output file.
+type mediaTags struct {
+	Title   string
+	Artist  string
+	Album   string
+	Date    string
+	Comment string
+	Genre   string
+	Credits string
+	Cover   string
+	// Chapters are written by merge and embedMetadata.
+	Chapters []mp4.Chapter
+}
+
+// videoTags are the tags of the page of the video.
+func videoTags(info *VideoInfo, page Page) mediaTags {
+	tags := mediaTags{
+		Title:   info.Title,
+		Artist:  info.Uploader,
+		Genre:   info.Genre,
+		Cover:   info.Cover,
+		Comment: "https://www.bilibili.com/video/" + info.BvID,
+	}
+	if len(info.Description) != 0 {
+		tags.Comment = info.Description + "\n\n" + tags.Comment
+	}
+	if pubdate, err := time.Parse(time.RFC3339, info.PublishTime); err == nil {
+		tags.Date = pubdate.Format("2006-01-02")
+	}
+	credits := make([]string, 0, len(info.Staff))
+	for _, staff := range info.Staff {
+		credits = append(credits, staff.Title+": "+staff.Name)
+	}
+	tags.Credits = strings.Join(credits, ", ")
+	if len(info.Pages) > 1 {
+		tags.Title = page.Part
+		tags.Album = info.Title
+	}
+	return tags
+}
+
+func (tags mediaTags) ffmpegArgs() []string {
+	args := make([]string, 0, 14)
+	for _, kv := range [][2]string{
+		{"title", tags.Title},
+		{"artist", tags.Artist},
+		{"album", tags.Album},
+		{"date", tags.Date},
+		{"comment", tags.Comment},
+		{"genre", tags.Genre},
+		{"composer", tags.Credits},
+	} {
+		if len(kv[1]) != 0 {
+			args = append(args, "-metadata", kv[0]+"="+kv[1])
+		}
+	}
+	return args
+}
+
+type audioCodec struct {
+	encoder string
+	cover   bool
+}
+
+var audioCodecs = map[string]audioCodec{
+	"m4a":  {encoder: "copy", cover: true},
+	"mp3":  {encoder: "libmp3lame", cover: true},
+	"opus": {encoder: "libopus", cover: false},
+	"flac": {encoder: "flac", cover: true},
+}
+
+func checkAudioFormat() error {
+	if _, ok := audioCodecs[audioFormat]; !ok {
+		return fmt.Errorf("invalid audio format: %s", audioFormat)
+	}
+	return nil
+}
+
+// downloadAudio downloads only the selected dash audio stream of the page.
+func downloadAudio(bvID string, cid int64, tags mediaTags, values filename.Values) error {
+	playUrlResp, err := client.PlayUrl(bvID, cid, 0, bilibili.FnvalDashAll)
+	if err != nil {
+		return err
+	}
+	audios := playUrlResp.AudioStreams()
+	if len(audios) == 0 {
+		return fmt.Errorf("no audio stream of %s", bvID)
+	}
+	audioQualities := make([]bilibili.Qn, 0, len(audios))
+	for _, audio := range audios {
+		audioQualities = append(audioQualities, bilibili.Qn(audio.ID))
+	}
+	selectedAudioQuality, err := selectMediaQuality("Please select audio quality", audioQualities, sizeLabel(playUrlResp))
+	if err != nil {
+		return err
+	}
+
+	if _, ok := archived(values.AID, cid, selectedAudioQuality); ok {
+		return nil
+	}
+	values.AudioQuality = playUrlResp.QnDescription(selectedAudioQuality)
+	values.Quality = values.AudioQuality
+	output, err := outputPath(values, "."+audioFormat)
+	if err != nil {
+		return err
+	}
+
+	audioTmp, err := os.CreateTemp(outputDir, "bilibili_audio_*.m4s")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(audioTmp.Name())
+	expected := time.Duration(playUrlResp.Data.Timelength) * time.Millisecond
+	source := newPlayUrlCache(bvID, cid, 0, bilibili.FnvalDashAll, playUrlResp).dashAudioSource(selectedAudioQuality)
+	size := probeSizeOr(source, streamSize(playUrlResp, selectedAudioQuality))
+	fmt.Printf("Downloading %s (~%s)\n", values.AudioQuality, formatSize(size))
+	// the stream and the converted output exist at the same time
+	if err = checkDiskSpace(path.Dir(output), size*2); err != nil {
+		return err
+	}
+	if err = downloadVerifiedMedia("Audio", source, audioTmp, expected); err != nil {
+		audioTmp.Close()
+		return err
+	}
+	if err = audioTmp.Close(); err != nil {
+		return err
+	}
+
+	if err = checkFFmpeg(); err != nil {
+		if audioFormat != "m4a" {
+			return fmt.Errorf("audio format %s requires ffmpeg: %w", audioFormat, err)
+		}
+		// the dash audio stream is a fragmented mp4 already, it is tagged without ffmpeg
+		if err = os.Rename(audioTmp.Name(), output); err != nil {
+			return err
+		}
+		embedMetadata(output, tags)
+		recordArchive(values.AID, cid, selectedAudioQuality, output)
+		return runHooks(newHookValues(output, values, expected))
+	}
+
+	cover := ""
+	if audioCodecs[audioFormat].cover {
+		if cover = downloadTagsCover(tags, outputDir); len(cover) != 0 {
+			defer os.Remove(cover)
+		}
+	}
+	ins.Start()
+	defer ins.Stop()
+	if err = convertAudio(audioTmp.Name(), cover, output, audioFormat, tags); err != nil {
+		return err
+	}
+	recordArchive(values.AID, cid, selectedAudioQuality, output)
+	return runHooks(newHookValues(output, values, expected))
+}
+
+// convertAudio writes the audio into output in the given format, embedding the tags and the cover if any.
+func convertAudio(audio, cover, output, format string, tags mediaTags) error {
+	args := convertAudioArgs(audio, cover, format, tags)
+	tmp, err := tempOutput(output)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	cmd := exec.CommandContext(ctx, "ffmpeg", append(args, tmp)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %s", err, stderr.String())
+	}
+	if err = os.Rename(tmp, output); err != nil {
+		return err
+	}
+	emit(progress.Event{Type: progress.EventMerged, Path: output})
+	fmt.Printf("%s is extracted from audio %s.\n", output, audio)
+	return nil
+}
+
+// convertAudioArgs are the ffmpeg arguments of convertAudio without the output.
+// An audio file in the format already is copied, encoding it again would only lose quality.
+func convertAudioArgs(audio, cover, format string, tags mediaTags) []string {
+	encoder := audioCodecs[format].encoder
+	if strings.EqualFold(path.Ext(audio), "."+format) {
+		encoder = "copy"
+	}
+	args := []string{"-y", "-i", audio}
+	if len(cover) != 0 {
+		args = append(args, "-i", cover, "-map", "0:a", "-map", "1:v", "-c:v", "copy", "-disposition:v", "attached_pic")
+	} else {
+		args = append(args, "-map", "0:a")
+	}
+	args = append(args, "-c:a", encoder)
+	switch format {
+	case "m4a":
+		// FLAC (Hi-Res) audio in mp4 is still marked as experimental by ffmpeg
+		args = append(args, "-strict", "experimental")
+	case "mp3":
+		if encoder != "copy" {
+			args = append(args, "-q:a", "0")
+		}
+		args = append(args, "-id3v2_version", "3")
+	case "opus":
+		if encoder != "copy" {
+			args = append(args, "-b:a", "192k")
+		}
+	}
+	return append(args, tags.ffmpegArgs()...)
+}
+
+// downloadTagsCover downloads the cover of tags into a temp file of dir, it is empty when there is no cover or the download fails.
+func downloadTagsCover(tags mediaTags, dir string) string {
+	if len(tags.Cover) == 0 {
+		return ""
+	}
+	cover, err := downloadCover(tags.Cover, dir)
+	if err != nil {
+		fmt.Printf("Download cover failed: %v\n", err)
+		return ""
+	}
+	return cover
+}
+
+// embedMetadata writes the tags and cover into the mp4 or m4a output without ffmpeg, failures are reported only.
+func embedMetadata(output string, tags mediaTags) {
+	if !isMP4(output) {
+		return
+	}
+	var cover []byte
+	if name := downloadTagsCover(tags, path.Dir(output)); len(name) != 0 {
+		cover, _ = os.ReadFile(name)
+		os.Remove(name)
+	}
+	writeMetadata(output, tags, cover)
+}
+
+func isMP4(output string) bool {
+	ext := path.Ext(output)
+	return strings.EqualFold(ext, ".mp4") || strings.EqualFold(ext, ".m4a")
+}
+
+// writeMetadata is embedMetadata with the cover image downloaded already, it may be empty.
+func writeMetadata(output string, tags mediaTags, cover []byte) {
+	metadata := mp4.Metadata{
+		Title:    tags.Title,
+		Artist:   tags.Artist,
+		Album:    tags.Album,
+		Date:     tags.Date,
+		Comment:  tags.Comment,
+		Genre:    tags.Genre,
+		Credits:  tags.Credits,
+		Chapters: tags.Chapters,
+		Cover:    cover,
+	}
+	if err := mp4.WriteMetadata(output, metadata); err != nil {
+		fmt.Printf("Write the metadata of %s failed: %v\n", output, err)
+	}
+}
+
+// downloadCover downloads the cover image into a temp file of dir.
+func downloadCover(coverUrl, dir string) (string, error) {
+	ext := ".jpg"
+	if u, err := url.Parse(coverUrl); err == nil && len(path.Ext(u.Path)) != 0 {
+		ext = path.Ext(u.Path)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.Replace(coverUrl, "http://", "https://", 1), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+	file, err := os.CreateTemp(dir, "bilibili_cover_*"+ext)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err = io.Copy(file, resp.Body); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
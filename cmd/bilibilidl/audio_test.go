@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVideoTags(t *testing.T) {
+	info := &VideoInfo{
+		BvID:        "BV1xx",
+		Title:       "合集",
+		Uploader:    "up",
+		Genre:       "音乐",
+		Description: "desc",
+		PublishTime: "2023-01-02T15:04:05+08:00",
+		Staff:       []Staff{{Name: "up", Title: "UP主"}, {Name: "mix", Title: "混音"}},
+		Pages:       []Page{{Part: "P1"}, {Part: "P2"}},
+	}
+	tags := videoTags(info, info.Pages[1])
+	assert.Equal(t, "P2", tags.Title)
+	assert.Equal(t, "合集", tags.Album)
+	assert.Equal(t, "2023-01-02", tags.Date)
+	assert.Equal(t, "UP主: up, 混音: mix", tags.Credits)
+	assert.Equal(t, "desc\n\nhttps://www.bilibili.com/video/BV1xx", tags.Comment)
+	assert.Contains(t, tags.ffmpegArgs(), "composer=UP主: up, 混音: mix")
+	assert.Contains(t, tags.ffmpegArgs(), "genre=音乐")
+}
+
+func TestConvertAudioArgs(t *testing.T) {
+	args := convertAudioArgs("song.m4s", "", "mp3", mediaTags{Title: "song"})
+	assert.Equal(t, []string{"-y", "-i", "song.m4s", "-map", "0:a", "-c:a", "libmp3lame", "-q:a", "0", "-id3v2_version", "3", "-metadata", "title=song"}, args)
+	// the stream in the format already is only tagged
+	args = convertAudioArgs("bilibili_audio_1.mp3", "cover.jpg", "mp3", mediaTags{Title: "song"})
+	assert.Equal(t, []string{"-y", "-i", "bilibili_audio_1.mp3", "-i", "cover.jpg", "-map", "0:a", "-map", "1:v", "-c:v", "copy", "-disposition:v", "attached_pic",
+		"-c:a", "copy", "-id3v2_version", "3", "-metadata", "title=song"}, args)
+	args = convertAudioArgs("bilibili_audio_1.flac", "", "flac", mediaTags{})
+	assert.Equal(t, []string{"-y", "-i", "bilibili_audio_1.flac", "-map", "0:a", "-c:a", "copy"}, args)
+}
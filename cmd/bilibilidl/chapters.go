@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/misssonder/bilibili/pkg/mp4"
+)
+
+// viewPointChapters are the view points (视频看点) of the page as chapters, failures are reported only.
+func viewPointChapters(bvID string, cid int64) []mp4.Chapter {
+	info, err := client.PlayerInfo(bvID, cid)
+	if err != nil {
+		fmt.Printf("Get the view points of %s failed: %v\n", bvID, err)
+		return nil
+	}
+	chapters := make([]mp4.Chapter, 0, len(info.Data.ViewPoints))
+	for _, point := range info.Data.ViewPoints {
+		chapters = append(chapters, mp4.Chapter{
+			Start: time.Duration(point.From) * time.Second,
+			End:   time.Duration(point.To) * time.Second,
+			Title: point.Content,
+		})
+	}
+	return chapters
+}
+
+// pageChapters are the chapters of the parts which are joined one after another.
+func pageChapters(titles []string, durations []time.Duration) []mp4.Chapter {
+	chapters := make([]mp4.Chapter, 0, len(titles))
+	var start time.Duration
+	for i, title := range titles {
+		chapters = append(chapters, mp4.Chapter{Start: start, End: start + durations[i], Title: title})
+		start += durations[i]
+	}
+	return chapters
+}
+
+// writeFFMetadata writes the chapters into a ffmetadata file of dir for ffmpeg to read.
+func writeFFMetadata(dir string, chapters []mp4.Chapter) (string, error) {
+	file, err := os.CreateTemp(dir, "bilibili_chapters_*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err = file.WriteString(ffmetadata(chapters)); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+func ffmetadata(chapters []mp4.Chapter) string {
+	var builder strings.Builder
+	builder.WriteString(";FFMETADATA1\n")
+	for i, chapter := range chapters {
+		end := chapter.End
+		if i+1 < len(chapters) && (end <= chapter.Start || end > chapters[i+1].Start) {
+			end = chapters[i+1].Start
+		}
+		if end < chapter.Start {
+			end = chapter.Start
+		}
+		fmt.Fprintf(&builder, "[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n",
+			chapter.Start.Milliseconds(), end.Milliseconds(), ffmetadataEscaper.Replace(chapter.Title))
+	}
+	return builder.String()
+}
+
+var ffmetadataEscaper = strings.NewReplacer(`\`, `\\`, "=", `\=`, ";", `\;`, "#", `\#`, "\n", "\\\n")
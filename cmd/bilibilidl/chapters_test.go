@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/misssonder/bilibili/pkg/mp4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPageChapters(t *testing.T) {
+	chapters := pageChapters([]string{"P1", "P2"}, []time.Duration{time.Minute, 2 * time.Minute})
+	assert.Equal(t, []mp4.Chapter{
+		{Start: 0, End: time.Minute, Title: "P1"},
+		{Start: time.Minute, End: 3 * time.Minute, Title: "P2"},
+	}, chapters)
+}
+
+func TestFFMetadata(t *testing.T) {
+	chapters := []mp4.Chapter{
+		// the view point ends after the next one starts
+		{Start: 0, End: 90 * time.Second, Title: "a=b;c"},
+		{Start: 60 * time.Second, Title: "#2"},
+	}
+	assert.Equal(t, ";FFMETADATA1\n"+
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=60000\ntitle=a\\=b\\;c\n"+
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=60000\nEND=60000\ntitle=\\#2\n", ffmetadata(chapters))
+}
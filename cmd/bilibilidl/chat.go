@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/misssonder/bilibili/pkg/danmaku"
+	"github.com/misssonder/bilibili/pkg/livechat"
+)
+
+// chatRecorder saves the chat alongside the recorded files: the events as json lines in name.chat.jsonl,
+// and the danmaku in name.danmaku.xml timed from the start of the file.
+type chatRecorder struct {
+	mu      sync.Mutex
+	roomID  int64
+	file    *os.File
+	name    string
+	start   time.Time
+	danmaku []danmaku.Danmaku
+}
+
+// chatLine is a line of the chat file.
+type chatLine struct {
+	Cmd  string         `json:"cmd"`
+	Data livechat.Event `json:"data"`
+}
+
+func chatPath(output string) string {
+	return strings.TrimSuffix(output, path.Ext(output)) + ".chat.jsonl"
+}
+
+// open starts the chat of the recorded file output, the events before it are dropped.
+func (c *chatRecorder) open(output string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	name := chatPath(output)
+	file, err := os.Create(name)
+	if err != nil {
+		fmt.Printf("Save the chat to %s failed: %v\n", name, err)
+		return
+	}
+	c.file, c.name, c.start, c.danmaku = file, output, time.Now(), nil
+}
+
+// close saves the chat of the current file, it is removed with the file when keep is false.
+func (c *chatRecorder) close(keep bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return
+	}
+	c.file.Close()
+	c.file = nil
+	if !keep {
+		os.Remove(chatPath(c.name))
+		return
+	}
+	name := danmakuPath(c.name, danmakuFormatXML)
+	err := writeDanmaku(name, func(w io.Writer) error {
+		return danmaku.WriteXML(w, c.roomID, c.danmaku)
+	})
+	if err != nil {
+		fmt.Printf("Save the danmaku to %s failed: %v\n", name, err)
+		return
+	}
+	fmt.Printf("%s is saved with %d danmaku.\n", name, len(c.danmaku))
+}
+
+func (c *chatRecorder) add(event livechat.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return
+	}
+	line, err := json.Marshal(chatLine{Cmd: event.Cmd(), Data: event})
+	if err != nil {
+		return
+	}
+	if _, err = c.file.Write(append(line, '\n')); err != nil {
+		fmt.Printf("Save the chat to %s failed: %v\n", chatPath(c.name), err)
+		c.file.Close()
+		c.file = nil
+		return
+	}
+	if d, ok := event.(*livechat.Danmaku); ok {
+		c.danmaku = append(c.danmaku, danmaku.Danmaku{
+			Progress: time.Since(c.start),
+			Mode:     danmaku.Mode(d.Mode),
+			FontSize: d.FontSize,
+			Color:    d.Color,
+			MidHash:  fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(strconv.FormatInt(d.Uid, 10)))),
+			Content:  d.Content,
+			Ctime:    d.Time,
+		})
+	}
+}
+
+// recordChat connects the chat of the room until ctx is canceled, it reconnects with a new token on failures.
+func recordChat(ctx context.Context, roomID int64, chat *chatRecorder) {
+	events := make(chan livechat.Event)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for event := range events {
+			chat.add(event)
+		}
+	}()
+	defer func() {
+		close(events)
+		<-done
+	}()
+
+	for ctx.Err() == nil {
+		info, err := client.DanmuInfo(roomID)
+		if err == nil {
+			err = livechat.NewClient(roomID, liveChatUid, info.Data.Token, info.ChatUrls()).Run(ctx, events)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		fmt.Printf("The chat of room %d is interrupted, reconnecting in %s: %v\n", roomID, liveRetryInterval, err)
+		if !sleepContext(ctx, liveRetryInterval) {
+			return
+		}
+	}
+}
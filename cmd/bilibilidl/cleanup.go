@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// tempFileRegexp matches the temp files created by bilibilidl, see tempOutput and the os.CreateTemp calls.
+var tempFileRegexp = regexp.MustCompile(`^bilibili_(video|audio|output|segment|concat|cover|chapters|metadata)_\d+`)
+
+// orphanAge is how long a temp file is untouched before it is considered orphaned,
+// younger ones may still belong to a running process.
+const orphanAge = time.Hour
+
+// tempOutput creates the temp file to write output into, it is renamed to output once complete.
+// The extension is kept so ffmpeg recognises the format.
+func tempOutput(output string) (string, error) {
+	file, err := os.CreateTemp(path.Dir(output), "bilibili_output_*"+path.Ext(output))
+	if err != nil {
+		return "", err
+	}
+	return file.Name(), file.Close()
+}
+
+// sweepTempFiles removes the orphaned temp files left in dir by interrupted runs.
+func sweepTempFiles(dir string, recursive bool) {
+	_ = filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if p != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !tempFileRegexp.MatchString(info.Name()) || time.Since(info.ModTime()) < orphanAge {
+			return nil
+		}
+		if err = os.Remove(p); err != nil {
+			logrus.Warnf("Remove orphaned temp file %s failed: %v", p, err)
+		} else {
+			logrus.Infof("Removed orphaned temp file %s", p)
+		}
+		return nil
+	})
+}
+
+// isCanceled reports whether the process was interrupted.
+func isCanceled() bool {
+	return ctx.Err() == context.Canceled
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestSweepTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	orphaned := path.Join(dir, "bilibili_video_123.m4s")
+	// WriteMetadata of pkg/mp4 leaves it when the process is killed
+	metadata := path.Join(dir, "bilibili_metadata_789.mp4")
+	running := path.Join(dir, "bilibili_output_456.mp4")
+	kept := path.Join(dir, "video.mp4")
+	for _, p := range []string{orphaned, metadata, running, kept} {
+		if err := os.WriteFile(p, nil, 0644); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	old := time.Now().Add(-2 * orphanAge)
+	for _, p := range []string{orphaned, metadata, kept} {
+		if err := os.Chtimes(p, old, old); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	sweepTempFiles(dir, false)
+	for _, p := range []string{orphaned, metadata} {
+		if PathExists(p) {
+			t.Errorf("%s is not removed", p)
+		}
+	}
+	if !PathExists(running) || !PathExists(kept) {
+		t.Errorf("unexpected removal")
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/misssonder/bilibili/pkg/mp4"
+	"github.com/misssonder/bilibili/pkg/progress"
+)
+
+// downloadSegments downloads the durl segments in order and joins them into output.
+// If the segments can not be joined, they are kept next to output.
+// A zero expected duration skips the duration verification.
+func downloadSegments(sources []mediaSource, output string, expected time.Duration) error {
+	if len(sources) == 0 {
+		return fmt.Errorf("no segment to download")
+	}
+	if len(sources) == 1 {
+		return downloadToFile("Video", sources[0], output, expected)
+	}
+
+	segments := make([]string, 0, len(sources))
+	names := make([]string, 0, len(sources))
+	defer func() {
+		for _, segment := range segments {
+			_ = os.Remove(segment)
+		}
+	}()
+	for i, source := range sources {
+		u, err := source(false)
+		if err != nil {
+			return err
+		}
+		name := segmentName(output, u, i)
+		segment, err := tempSegment(name)
+		if err != nil {
+			return err
+		}
+		segments = append(segments, segment)
+		names = append(names, name)
+		if err = downloadToFile(fmt.Sprintf("Part %d/%d", i+1, len(sources)), source, segment, 0); err != nil {
+			return err
+		}
+	}
+
+	if err := concat(segments, output, nil); err != nil {
+		if isCanceled() {
+			return err
+		}
+		kept := make([]string, 0, len(segments))
+		for i, segment := range segments {
+			if err := os.Rename(segment, names[i]); err != nil {
+				return err
+			}
+			kept = append(kept, names[i])
+		}
+		fmt.Printf("Join segments failed: %v\nThe segments are kept as:\n%s\n", err, strings.Join(kept, "\n"))
+		return nil
+	}
+	if err := verifyMedia(output, expected); err != nil {
+		return fmt.Errorf("%s fails the verification: %w", output, err)
+	}
+	return nil
+}
+
+// downloadToFile downloads url into a temp file which is renamed to output once verified.
+func downloadToFile(title string, source mediaSource, output string, expected time.Duration) error {
+	tmp, err := tempOutput(output)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	writer, err := os.OpenFile(tmp, os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if err = downloadVerifiedMedia(title, source, writer, expected); err != nil {
+		writer.Close()
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, output)
+}
+
+func tempSegment(name string) (string, error) {
+	file, err := os.CreateTemp(path.Dir(name), "bilibili_segment_*"+path.Ext(name))
+	if err != nil {
+		return "", err
+	}
+	return file.Name(), file.Close()
+}
+
+// segmentName names the i-th segment after output, keeping the extension of the segment url.
+func segmentName(output, segmentUrl string, i int) string {
+	ext := path.Ext(output)
+	if u, err := url.Parse(segmentUrl); err == nil && len(path.Ext(u.Path)) != 0 {
+		ext = path.Ext(u.Path)
+	}
+	return fmt.Sprintf("%s.part%d%s", strings.TrimSuffix(output, path.Ext(output)), i+1, ext)
+}
+
+// concat joins the segments into output with the ffmpeg concat demuxer, the chapters are optional.
+func concat(segments []string, output string, chapters []mp4.Chapter) error {
+	if err := checkFFmpeg(); err != nil {
+		return err
+	}
+	list, err := os.CreateTemp(path.Dir(output), "bilibili_concat_*.txt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(list.Name())
+	for _, segment := range segments {
+		abs, err := filepath.Abs(segment)
+		if err != nil {
+			list.Close()
+			return err
+		}
+		if _, err = fmt.Fprintf(list, "file '%s'\n", strings.ReplaceAll(abs, "'", `'\''`)); err != nil {
+			list.Close()
+			return err
+		}
+	}
+	if err = list.Close(); err != nil {
+		return err
+	}
+
+	tmp, err := tempOutput(output)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	args := []string{"-y",
+		"-f", "concat",
+		"-safe", "0",
+		"-i", list.Name(),
+	}
+	if len(chapters) != 0 {
+		metadata, err := writeFFMetadata(path.Dir(output), chapters)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(metadata)
+		args = append(args, "-i", metadata, "-map", "0", "-map_chapters", "1")
+	}
+	cmd := exec.CommandContext(ctx, "ffmpeg", append(args, "-c", "copy", tmp)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %s", err, stderr.String())
+	}
+	if err = os.Rename(tmp, output); err != nil {
+		return err
+	}
+	emit(progress.Event{Type: progress.EventMerged, Path: output})
+	fmt.Printf("%s is joined from %d segments.\n", output, len(segments))
+	return nil
+}
@@ -0,0 +1,10 @@
+package main
+
+import "testing"
+
+func TestSegmentName(t *testing.T) {
+	name := segmentName("out/video.mp4", "https://upos-sz-mirrorcos.bilivideo.com/upgcxcode/32/44/1/1-1-80.flv?e=ig8euxZM2rNcNbdl", 1)
+	if name != "out/video.part2.flv" {
+		t.Errorf("unexpected segment name %s", name)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/misssonder/bilibili/pkg/danmaku"
+	"github.com/spf13/pflag"
+)
+
+const (
+	danmakuFormatXML = "xml"
+	danmakuFormatASS = "ass"
+)
+
+var (
+	danmakuFormats []string
+	danmakuOptions = danmaku.DefaultASSOptions
+)
+
+func addDanmakuFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringSliceVar(&danmakuFormats, "danmaku", nil, "Save the danmaku next to the video as xml, ass or both like xml,ass.")
+	flagSet.Float64Var(&danmakuOptions.Area, "danmaku-area", danmakuOptions.Area, "The part of the screen the scrolling danmaku take, from 0 to 1, the lower the fewer danmaku.")
+	flagSet.BoolVar(&danmakuOptions.Overlap, "danmaku-overlap", false, "Keep the danmaku which find no free lane by overlapping others instead of dropping them.")
+	flagSet.DurationVar(&danmakuOptions.ScrollDuration, "danmaku-duration", danmakuOptions.ScrollDuration, "How long a scrolling danmaku crosses the screen.")
+	flagSet.Float64Var(&danmakuOptions.Opacity, "danmaku-opacity", danmakuOptions.Opacity, "The opacity of the danmaku, from 0 to 1.")
+}
+
+func checkDanmakuFormats() error {
+	for _, format := range danmakuFormats {
+		if format != danmakuFormatXML && format != danmakuFormatASS {
+			return fmt.Errorf("invalid danmaku format: %s", format)
+		}
+	}
+	return nil
+}
+
+// danmakuPath is the danmaku next to output, like video.danmaku.ass.
+func danmakuPath(output, format string) string {
+	return fmt.Sprintf("%s.danmaku.%s", strings.TrimSuffix(output, path.Ext(output)), format)
+}
+
+// danmakuASSOptions fits the height of the options to the video, so the danmaku keep their size on any aspect ratio.
+func danmakuASSOptions(dimension Dimension) danmaku.ASSOptions {
+	options := danmakuOptions
+	if dimension.Width > 0 && dimension.Height > 0 {
+		options.Width = options.Height * dimension.Width / dimension.Height
+	}
+	return options
+}
+
+// downloadDanmaku saves the danmaku of the cid next to output in the --danmaku formats.
+// All danmaku come from the segments of the video, the capped XML list is the fallback.
+// Failures are reported only, the video is downloaded already.
+func downloadDanmaku(cid int64, output string, dimension Dimension, duration time.Duration) {
+	if len(danmakuFormats) == 0 {
+		return
+	}
+	list, err := client.Danmaku(cid, duration)
+	if err != nil {
+		fmt.Printf("Get the danmaku segments of %d failed, trying the danmaku list: %v\n", cid, err)
+		if list, err = client.DanmakuXML(cid); err != nil {
+			fmt.Printf("Get the danmaku of %d failed: %v\n", cid, err)
+			return
+		}
+	}
+	for _, format := range danmakuFormats {
+		name := danmakuPath(output, format)
+		err = writeDanmaku(name, func(w io.Writer) error {
+			if format == danmakuFormatXML {
+				return danmaku.WriteXML(w, cid, list)
+			}
+			return danmaku.WriteASS(w, list, danmakuASSOptions(dimension))
+		})
+		if err != nil {
+			fmt.Printf("Save the danmaku to %s failed: %v\n", name, err)
+			continue
+		}
+		fmt.Printf("%s is saved with %d danmaku.\n", name, len(list))
+	}
+}
+
+func writeDanmaku(name string, write func(w io.Writer) error) error {
+	tmp, err := tempOutput(name)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err = write(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDanmakuPath(t *testing.T) {
+	assert.Equal(t, "dir/video.danmaku.ass", danmakuPath("dir/video.mp4", danmakuFormatASS))
+	assert.Equal(t, "dir/video.danmaku.xml", danmakuPath("dir/video.mp4", danmakuFormatXML))
+}
+
+func TestDanmakuASSOptions(t *testing.T) {
+	options := danmakuASSOptions(Dimension{Width: 1080, Height: 1920})
+	assert.Equal(t, 607, options.Width)
+	assert.Equal(t, 1080, options.Height)
+	options = danmakuASSOptions(Dimension{})
+	assert.Equal(t, 1920, options.Width)
+}
@@ -10,9 +10,16 @@ import (
 	"os/exec"
 	"path"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/misssonder/bilibili/internal/filename"
 	bilibili "github.com/misssonder/bilibili/pkg/client"
 	"github.com/misssonder/bilibili/pkg/errors"
+	"github.com/misssonder/bilibili/pkg/progress"
+	"github.com/misssonder/bilibili/pkg/ratelimit"
+	"github.com/misssonder/bilibili/pkg/subtitle"
 	"github.com/misssonder/bilibili/pkg/video"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -28,18 +35,43 @@ var (
 
 var downloadCmd = &cobra.Command{
 	Use:   "download",
-	Short: "Download bilibili video through url/BVID/AVID.",
+	Short: "Download bilibili video through url/BVID/AVID, or songs through au/am ids.",
 	Args:  cobra.ExactArgs(1),
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		if err := checkDir(); err != nil {
 			return err
 		}
+		sweepTempFiles(outputDir, false)
+		if err := initArchive(); err != nil {
+			return err
+		}
 		if err := checkOutputFormat(); err != nil {
 			return err
 		}
+		if err := checkAudioFormat(); err != nil {
+			return err
+		}
+		if err := checkHooks(); err != nil {
+			return err
+		}
+		if err := checkSubtitleFormat(); err != nil {
+			return err
+		}
+		if err := checkDanmakuFormats(); err != nil {
+			return err
+		}
 		return login()
 	},
 	Run: func(cmd *cobra.Command, args []string) {
+		if video.IsAuID(args[0]) || video.IsAmID(args[0]) {
+			// the songs keep the format of their streams unless --audio-format is set
+			format := ""
+			if cmd.Flags().Changed("audio-format") {
+				format = audioFormat
+			}
+			exitOnError(downloadMusic(args[0], format))
+			return
+		}
 		id, err := video.ExtractBvID(args[0])
 		if err != nil {
 			exitOnError(err)
@@ -50,8 +82,17 @@ var downloadCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(downloadCmd)
-	downloadCmd.Flags().StringVarP(&outputFile, "filename", "o", "", "The output file.")
+	downloadCmd.Flags().StringVarP(&outputFile, "filename", "o", "", "The output file, supports templates like {uploader}/{pubdate:2006-01-02} {title} (default {title}).")
 	downloadCmd.Flags().StringVarP(&outputDir, "directory", "d", ".", "The output directory.")
+	addArchiveFlags(downloadCmd.Flags())
+	addHookFlags(downloadCmd.Flags())
+	downloadCmd.Flags().BoolVar(&audioOnly, "audio-only", false, "Download the audio stream only.")
+	downloadCmd.Flags().StringVar(&subtitleLanguages, "subtitles", "", "Download the subtitles of the languages, like zh,en, cc for the ones not generated by AI, or all.")
+	downloadCmd.Flags().StringVar(&subtitleFormat, "sub-format", subtitle.FormatSRT, "The subtitle format ("+strings.Join(subtitle.Formats, "/")+").")
+	downloadCmd.Flags().BoolVar(&embedSubtitles, "embed-subs", false, "Embed the subtitles into the video as soft subtitles, requires ffmpeg.")
+	addDanmakuFlags(downloadCmd.Flags())
+	downloadCmd.Flags().BoolVar(&mergePages, "merge-pages", false, "Download all pages of a multi-part video into one file with a chapter per page.")
+	downloadCmd.Flags().StringVar(&audioFormat, "audio-format", "m4a", "The audio format of audio only mode (m4a/mp3/opus/flac), all but m4a require ffmpeg.")
 }
 
 func selectVideoInfo(info *VideoInfo) (Page, error) {
@@ -96,23 +137,18 @@ func selectFormat() (bilibili.Fnval, error) {
 	return formats[rows[format]], nil
 }
 
-func selectMediaQuality(title string, qns []bilibili.Qn) (bilibili.Qn, error) {
+func selectMediaQuality(title string, qns []bilibili.Qn, label func(bilibili.Qn) string) (bilibili.Qn, error) {
 	var marshalQns = func(qns []bilibili.Qn) []bilibili.Qn {
 		qns = stream.NewSliceByOrdered(qns).Distinct().ToSlice()
-		tmp := make([]int, 0, len(qns))
-		for _, qn := range qns {
-			tmp = append(tmp, int(qn))
-		}
-		sort.Ints(tmp)
-		for i, t := range tmp {
-			qns[i] = bilibili.Qn(t)
-		}
+		sort.Slice(qns, func(i, j int) bool {
+			return qns[i].Less(qns[j])
+		})
 		return qns
 	}
 	qns = marshalQns(qns)
 	rows := make([]string, 0, len(qns))
 	for _, qn := range qns {
-		rows = append(rows, qn.String())
+		rows = append(rows, label(qn))
 	}
 	selected, err := selectList(title, rows)
 	if err != nil {
@@ -123,9 +159,11 @@ func selectMediaQuality(title string, qns []bilibili.Qn) (bilibili.Qn, error) {
 
 func download(id string) error {
 	var (
-		bvID  string
-		cid   int64
-		title string
+		bvID      string
+		cid       int64
+		tags      mediaTags
+		values    filename.Values
+		dimension Dimension
 	)
 	if video.IsSSID(id) || video.IsEpID(id) {
 		info, err := getSeasonInfo(id)
@@ -138,30 +176,48 @@ func download(id string) error {
 		}
 		cid = episode.CID
 		bvID = episode.BvID
-		title = episode.Title
+		dimension = episode.Dimension
+		tags = mediaTags{Title: episode.Title, Album: info.Title, Cover: episode.Cover}
+		values = filename.Values{
+			Title:   episode.Title,
+			Part:    episode.Title,
+			BvID:    episode.BvID,
+			AID:     episode.AID,
+			CID:     episode.CID,
+			Episode: episode.Number,
+		}
 	} else {
 		info, err := getVideoInfo(id)
 		if err != nil {
 			return err
 		}
+		if info.SteinGate && !audioOnly {
+			return downloadSteinGate(info)
+		}
+		if mergePages && !audioOnly && len(info.Pages) > 1 {
+			return downloadPages(info)
+		}
 		page, err := selectVideoInfo(info)
 		if err != nil {
 			return err
 		}
 		cid = page.CID
 		bvID = id
-		title = info.Title
+		dimension = page.Dimension
+		values = videoValues(info, page)
+		tags = videoTags(info, page)
 	}
 
+	if audioOnly {
+		return downloadAudio(bvID, cid, tags, values)
+	}
+	tags.Chapters = viewPointChapters(bvID, cid)
+
 	format, err := selectFormat()
 	if err != nil {
 		return err
 	}
 
-	if len(outputFile) == 0 {
-		outputFile = fmt.Sprintf("%s.mp4", title)
-	}
-
 	switch format {
 	case bilibili.FnvalMP4:
 		playUrlResp, err := client.PlayUrl(bvID, cid, bilibili.Qn4k, format)
@@ -169,18 +225,46 @@ func download(id string) error {
 			return err
 		}
 
-		writer, err := getDownloadDestFile(outputDir, outputFile)
+		quality := bilibili.Qn(playUrlResp.Data.Quality)
+		if _, ok := archived(values.AID, cid, quality); ok {
+			return nil
+		}
+		values.Quality = playUrlResp.QnDescription(quality)
+		values.Codec = "avc"
+		output, err := outputPath(values, ".mp4")
 		if err != nil {
 			return err
 		}
-		defer writer.Close()
-
-		return downloadMedia("Video", playUrlResp.Data.Durl[0].URL, writer)
+		sources, err := newPlayUrlCache(bvID, cid, bilibili.Qn4k, format, playUrlResp).segmentSources()
+		if err != nil {
+			return err
+		}
+		size := segmentsSize(playUrlResp)
+		fmt.Printf("Downloading %s (~%s)\n", values.Quality, formatSize(size))
+		// the segments and the joined output exist at the same time
+		if len(sources) > 1 {
+			size *= 2
+		}
+		if err = checkDiskSpace(path.Dir(output), size); err != nil {
+			return err
+		}
+		expected := time.Duration(playUrlResp.Data.Timelength) * time.Millisecond
+		if err = downloadSegments(sources, output, expected); err != nil {
+			return err
+		}
+		if PathExists(output) {
+			embedMetadata(output, tags)
+			downloadSubtitles(bvID, cid, output)
+			downloadDanmaku(cid, output, dimension, expected)
+			recordArchive(values.AID, cid, quality, output)
+			return runHooks(newHookValues(output, values, expected))
+		}
+		return nil
 	case bilibili.FnvalDash:
 		if err = checkFFmpeg(); err != nil {
 			return err
 		}
-		playUrlResp, err := client.PlayUrl(bvID, cid, 0, format)
+		playUrlResp, err := client.PlayUrl(bvID, cid, 0, bilibili.FnvalDashAll)
 		if err != nil {
 			return err
 		}
@@ -200,68 +284,213 @@ func download(id string) error {
 			for _, video := range playUrlResp.Data.Dash.Video {
 				videoQualities = append(videoQualities, bilibili.Qn(video.ID))
 			}
-			selectedVideoQuality, err = selectMediaQuality("Please select video quality", videoQualities)
+			selectedVideoQuality, err = selectMediaQuality("Please select video quality", videoQualities, sizeLabel(playUrlResp))
 			if err != nil {
 				return err
 			}
 		}
 		{
-			audioQualities := make([]bilibili.Qn, 0, len(playUrlResp.Data.Dash.Audio))
+			audios := playUrlResp.AudioStreams()
+			audioQualities := make([]bilibili.Qn, 0, len(audios))
 			audioTmp, err = os.CreateTemp(outputDir, "bilibili_audio_*.m4s")
 			if err != nil {
 				return err
 			}
 			defer os.Remove(audioTmp.Name())
-			for _, audio := range playUrlResp.Data.Dash.Audio {
+			for _, audio := range audios {
 				audioQualities = append(audioQualities, bilibili.Qn(audio.ID))
 			}
-			selectedAudioQuality, err = selectMediaQuality("Please select audio quality", audioQualities)
+			selectedAudioQuality, err = selectMediaQuality("Please select audio quality", audioQualities, sizeLabel(playUrlResp))
 			if err != nil {
 				return err
 			}
 		}
-		if err = downloadMedia("Video", chooseMediaUrl(playUrlResp, selectedVideoQuality), videoTmp); err != nil {
+		if _, ok := archived(values.AID, cid, selectedVideoQuality); ok {
+			return nil
+		}
+		values.Quality = playUrlResp.QnDescription(selectedVideoQuality)
+		values.AudioQuality = playUrlResp.QnDescription(selectedAudioQuality)
+		selectedVideo := chooseDashVideo(playUrlResp, selectedVideoQuality, 0)
+		values.Codec = selectedVideo.CodecName()
+		output, err := outputPath(values, ".mp4")
+		if err != nil {
+			return err
+		}
+		expected := time.Duration(playUrlResp.Data.Timelength) * time.Millisecond
+		cache := newPlayUrlCache(bvID, cid, 0, bilibili.FnvalDashAll, playUrlResp)
+		videoSource := cache.dashVideoSource(selectedVideoQuality, selectedVideo.Codecid)
+		audioSource := cache.dashAudioSource(selectedAudioQuality)
+		size := probeSizeOr(videoSource, streamSize(playUrlResp, selectedVideoQuality)) +
+			probeSizeOr(audioSource, streamSize(playUrlResp, selectedAudioQuality))
+		fmt.Printf("Downloading %s and %s (~%s)\n", values.Quality, values.AudioQuality, formatSize(size))
+		// the streams and the merged output exist at the same time
+		if err = checkDiskSpace(path.Dir(output), size*2); err != nil {
 			return err
 		}
-		if err = downloadMedia("Audio", chooseMediaUrl(playUrlResp, selectedAudioQuality), audioTmp); err != nil {
+		if err = downloadVerifiedMedia("Video", videoSource, videoTmp, expected); err != nil {
 			return err
 		}
+		if err = downloadVerifiedMedia("Audio", audioSource, audioTmp, expected); err != nil {
+			return err
+		}
+		cover := downloadTagsCover(tags, path.Dir(output))
+		if len(cover) != 0 {
+			defer os.Remove(cover)
+		}
 		ins.Start()
 		defer ins.Stop()
-		_, err = merge(videoTmp.Name(), audioTmp.Name(), path.Join(outputDir, outputFile))
-
-		return err
+		if _, err = merge(videoTmp.Name(), audioTmp.Name(), output, tags, cover); err != nil {
+			return err
+		}
+		if err = verifyMedia(output, expected); err != nil {
+			return fmt.Errorf("%s fails the verification: %w", output, err)
+		}
+		downloadSubtitles(bvID, cid, output)
+		downloadDanmaku(cid, output, dimension, expected)
+		recordArchive(values.AID, cid, selectedVideoQuality, output)
+		return runHooks(newHookValues(output, values, expected))
 	}
 	return nil
 }
 
 func chooseMediaUrl(playUrlResp *bilibili.PlayUrlResp, qn bilibili.Qn) string {
-	if qn > 2048 {
-		for _, audio := range playUrlResp.Data.Dash.Audio {
+	if qn.IsAudio() {
+		audios := playUrlResp.AudioStreams()
+		for _, audio := range audios {
 			if audio.ID == int(qn) {
 				return audio.BaseURL
 			}
 		}
-		return playUrlResp.Data.Dash.Audio[0].BaseURL
+		return audios[0].BaseURL
 	} else {
-		for _, video := range playUrlResp.Data.Dash.Video {
-			if video.ID == int(qn) {
-				return video.BaseURL
-			}
+		return chooseDashVideo(playUrlResp, qn, 0).BaseURL
+	}
+
+}
+
+// chooseDashVideo returns the video stream of qn, preferring codecid unless it is zero.
+func chooseDashVideo(playUrlResp *bilibili.PlayUrlResp, qn bilibili.Qn, codecid int) bilibili.DashVideo {
+	var (
+		found bool
+		video bilibili.DashVideo
+	)
+	for _, v := range playUrlResp.Data.Dash.Video {
+		if v.ID != int(qn) {
+			continue
+		}
+		if codecid == 0 || v.Codecid == codecid {
+			return v
 		}
-		return playUrlResp.Data.Dash.Video[0].BaseURL
+		if !found {
+			found, video = true, v
+		}
+	}
+	if found {
+		return video
+	}
+	return playUrlResp.Data.Dash.Video[0]
+}
+
+// outputPath expands the output template into a free path under outputDir,
+// ext is appended when the template does not end with an extension.
+func outputPath(values filename.Values, ext string) (string, error) {
+	return expandOutput(outputDir, outputTemplate(), values, ext)
+}
+
+func outputTemplate() string {
+	if len(outputFile) == 0 {
+		return "{title}"
+	}
+	return outputFile
+}
+
+// expandOutput expands template under dir and creates the directories of the result.
+func expandOutput(dir, template string, values filename.Values, ext string) (string, error) {
+	output, err := resolveOutput(dir, template, values, ext)
+	if err != nil {
+		return "", err
 	}
+	if err = os.MkdirAll(path.Dir(output), os.ModePerm); err != nil {
+		return "", err
+	}
+	return filename.Unique(output), nil
+}
 
+// resolveOutput expands the output path without touching the disk.
+func resolveOutput(dir, template string, values filename.Values, ext string) (string, error) {
+	name, err := filename.Expand(template, values)
+	if err != nil {
+		return "", err
+	}
+	if len(path.Ext(template[strings.LastIndexByte(template, '}')+1:])) == 0 {
+		name += ext
+	}
+	return path.Join(dir, name), nil
 }
 
-func merge(video, audio, output string) (string, error) {
-	cmd := exec.Command("ffmpeg", "-y",
+func videoValues(info *VideoInfo, page Page) filename.Values {
+	pubdate, _ := time.Parse(time.RFC3339, info.PublishTime)
+	return filename.Values{
+		Title:    info.Title,
+		Part:     page.Part,
+		Page:     page.Page,
+		BvID:     info.BvID,
+		AID:      info.AID,
+		CID:      page.CID,
+		Uploader: info.Uploader,
+		Mid:      info.UploaderMid,
+		Pubdate:  pubdate,
+	}
+}
+
+// merge muxes the video and audio into output with the tags, a non-empty cover is attached as picture.
+func merge(video, audio, output string, tags mediaTags, cover string) (string, error) {
+	args := []string{"-y",
 		"-i", video,
 		"-i", audio,
-		"-c", "copy", // Just copy without re-encoding
-		"-shortest", // Finish encoding when the shortest input stream ends
-		output,
-	)
+	}
+	// all inputs go before the output options
+	inputs := 2
+	if len(cover) != 0 {
+		args = append(args, "-i", cover)
+		inputs++
+	}
+	chaptersInput := -1
+	if len(tags.Chapters) != 0 {
+		chapters, err := writeFFMetadata(path.Dir(output), tags.Chapters)
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(chapters)
+		args = append(args, "-i", chapters)
+		chaptersInput = inputs
+	}
+	if len(cover) != 0 {
+		// -shortest would stop at the single frame of the cover
+		args = append(args,
+			"-map", "0:v:0", "-map", "1:a:0", "-map", "2:v:0",
+			"-disposition:v:1", "attached_pic",
+			"-c", "copy")
+	} else {
+		args = append(args,
+			"-c", "copy", // Just copy without re-encoding
+			"-shortest", // Finish encoding when the shortest input stream ends
+		)
+	}
+	if chaptersInput >= 0 {
+		args = append(args, "-map_chapters", strconv.Itoa(chaptersInput))
+	}
+	args = append(args, tags.ffmpegArgs()...)
+	if strings.EqualFold(path.Ext(output), ".mp4") {
+		// FLAC (Hi-Res) audio in mp4 is still marked as experimental by ffmpeg
+		args = append(args, "-strict", "experimental")
+	}
+	tmp, err := tempOutput(output)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp)
+	cmd := exec.CommandContext(ctx, "ffmpeg", append(args, tmp)...)
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 	var stdout bytes.Buffer
@@ -287,23 +516,27 @@ func merge(video, audio, output string) (string, error) {
 		return "", err
 	}
 
+	if err = os.Rename(tmp, output); err != nil {
+		return "", err
+	}
+	emit(progress.Event{Type: progress.EventMerged, Path: output})
 	fmt.Printf("%s\n", stdout.String())
 	fmt.Printf("%s is merged from video %s and audio %s.\n", output, video, audio)
 	return output, nil
 }
 
-func getDownloadDestFile(dir, f string) (*os.File, error) {
-	filePath := path.Join(dir, f)
-	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
-	if err != nil {
-		return nil, err
+func downloadMedia(title, url string, writer io.Writer) (err error) {
+	var dest string
+	if file, ok := writer.(*os.File); ok {
+		dest = file.Name()
 	}
-	return file, nil
-}
-
-func downloadMedia(title, url string, writer io.Writer) error {
+	defer func() {
+		if err != nil {
+			emit(progress.Event{Type: progress.EventFailed, Title: title, Path: dest, Error: err.Error()})
+		}
+	}()
 	cli := &http.Client{}
-	request, err := http.NewRequest(http.MethodGet, url, nil)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return err
 	}
@@ -316,27 +549,43 @@ func downloadMedia(title, url string, writer io.Writer) error {
 	if resp.StatusCode != http.StatusOK {
 		return errors.ErrUnexpectedStatusCode(resp.StatusCode)
 	}
-	progress := mpb.New(mpb.WithWidth(64))
-	bar := progress.AddBar(
-		resp.ContentLength,
+	emit(progress.Event{Type: progress.EventStarted, Title: title, Path: dest, Total: resp.ContentLength})
+	reader := ratelimit.NewReader(resp.Body, globalLimiter, newDownloadLimiter())
+	var n int64
+	if progressListener != nil {
+		n, err = io.Copy(writer, progress.NewReader(reader, title, resp.ContentLength, progressListener))
+	} else {
+		bars := mpb.New(mpb.WithWidth(64))
+		bar := bars.AddBar(
+			resp.ContentLength,
 
-		mpb.PrependDecorators(
-			decor.Name(fmt.Sprintf("%s:", title)),
-			decor.OnComplete(
-				decor.Name("download... "), "done ",
+			mpb.PrependDecorators(
+				decor.Name(fmt.Sprintf("%s:", title)),
+				decor.OnComplete(
+					decor.Name("download... "), "done ",
+				),
+				decor.CountersKibiByte("% .2f / % .2f"),
+				decor.Percentage(decor.WCSyncSpace),
 			),
-			decor.CountersKibiByte("% .2f / % .2f"),
-			decor.Percentage(decor.WCSyncSpace),
-		),
-		mpb.AppendDecorators(
-			decor.EwmaETA(decor.ET_STYLE_GO, 90),
-			decor.Name(" | "),
-			decor.EwmaSpeed(decor.UnitKiB, "% .2f", 60),
-		),
-	)
-	reader := bar.ProxyReader(resp.Body)
-	_, err = io.Copy(writer, reader)
-	progress.Wait()
+			mpb.AppendDecorators(
+				decor.EwmaETA(decor.ET_STYLE_GO, 90),
+				decor.Name(" | "),
+				decor.EwmaSpeed(decor.UnitKiB, "% .2f", 60),
+			),
+		)
+		n, err = io.Copy(writer, bar.ProxyReader(reader))
+		// Wait blocks until every bar is done, an incomplete one never is unless it is aborted
+		if err != nil || n != resp.ContentLength {
+			bar.Abort(false)
+		}
+		bars.Wait()
+	}
+	if err == nil && resp.ContentLength > 0 && n != resp.ContentLength {
+		err = errIncompleteDownload{expected: resp.ContentLength, actual: n}
+	}
+	if err == nil {
+		emit(progress.Event{Type: progress.EventFinished, Title: title, Path: dest, Bytes: n, Total: resp.ContentLength})
+	}
 	return err
 }
 
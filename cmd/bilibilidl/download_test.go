@@ -1,8 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestDownload(t *testing.T) {
@@ -17,3 +24,42 @@ func TestDownload(t *testing.T) {
 		return
 	}
 }
+
+// firstWriter closes written on the first write.
+type firstWriter struct {
+	once    sync.Once
+	written chan struct{}
+}
+
+func (w *firstWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.written) })
+	return len(p), nil
+}
+
+func TestDownloadMediaCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(1<<20))
+		w.Write(make([]byte, 1024))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	defer func(parent context.Context) { ctx = parent }(ctx)
+	ctx = canceled
+	writer := &firstWriter{written: make(chan struct{})}
+	done := make(chan error, 1)
+	go func() { done <- downloadMedia("Video", server.URL, writer) }()
+
+	<-writer.written
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("unexpected error %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the download hangs after the cancellation")
+	}
+}
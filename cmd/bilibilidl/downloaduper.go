@@ -2,11 +2,14 @@ package main
 
 import (
 	"fmt"
+	"github.com/misssonder/bilibili/internal/filename"
+	bilibili "github.com/misssonder/bilibili/pkg/client"
 	"github.com/spf13/cobra"
+	"io"
 	"log"
 	"os"
-	"path"
 	"path/filepath"
+	"time"
 )
 
 var downloadUPerCmd = &cobra.Command{
@@ -14,6 +17,18 @@ var downloadUPerCmd = &cobra.Command{
 	Short: "download uper's videos from videos.yaml",
 	Args:  cobra.ExactArgs(1),
 	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkOutputFormat(); err != nil {
+			return err
+		}
+		if err := checkHooks(); err != nil {
+			return err
+		}
+		if !dryRun {
+			sweepTempFiles(getVideoLocation(), true)
+		}
+		if err := initArchive(); err != nil {
+			return err
+		}
 		return login()
 	},
 	Run: func(cmd *cobra.Command, args []string) {
@@ -21,75 +36,196 @@ var downloadUPerCmd = &cobra.Command{
 	},
 }
 
+var uperOutputTemplate string
+
 func init() {
 	rootCmd.AddCommand(downloadUPerCmd)
+	addArchiveFlags(downloadUPerCmd.Flags())
+	addDryRunFlag(downloadUPerCmd.Flags())
+	addHookFlags(downloadUPerCmd.Flags())
+	addFormatFlag(downloadUPerCmd.Flags())
+	downloadUPerCmd.Flags().StringVarP(&uperOutputTemplate, "filename", "o", "", "The output file under the uper's folder, supports the same templates as download (default {title}/{part}).")
 }
 
 func downloadUPerVideos(uper string) error {
 
 	videos := AllVideos[uper]
 
-	for _, v := range videos {
-		if v.Location == "" {
-			v, err := setAV(v)
-			if err != nil {
-				log.Printf("setAV failed: %v\n", err)
-				continue
-			}
-			ok := false
-			if v.VideoQuality != 0 {
-				_, ok, _ = downloadAndMergeVideo(v)
-			} else {
-				_, ok, _ = downloadVideo(v)
-			}
-			if ok {
-				content := MarshalYaml(videos)
-				WriteContent(getUPerVideosListFileLocation(uper), content)
+	plan, err := planUPerVideos(videos)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		return writeOutput(os.Stdout, plan, func(w io.Writer) {
+			writePlanOutput(w, plan)
+		})
+	}
+
+	archivedVideos := false
+	for _, item := range plan.Items {
+		if item.video != nil && item.Action == planActionSkip && len(item.Output) != 0 {
+			item.video.Location = item.Output
+			archivedVideos = true
+		}
+	}
+	if archivedVideos {
+		WriteContent(getUPerVideosListFileLocation(uper), MarshalYaml(videos))
+	}
+
+	pending := plan.pending()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	fmt.Printf("Downloading %d videos of %s (~%s)\n", len(pending), uper, formatSize(plan.Size))
+	if err := checkDiskSpace(getUPerVideosListFolderLocation(uper), plan.required()); err != nil {
+		return err
+	}
+
+	failedHooks := 0
+	for _, item := range pending {
+		if isCanceled() {
+			return ctx.Err()
+		}
+		v := item.video
+		ok := false
+		if v.VideoQuality != 0 {
+			_, ok, _ = downloadAndMergeVideo(v, item.cache)
+		} else {
+			_, ok, _ = downloadVideo(v, item.cache)
+		}
+		if ok {
+			recordArchive(v.AID, v.CID, item.quality, v.Location)
+			content := MarshalYaml(videos)
+			WriteContent(getUPerVideosListFileLocation(uper), content)
+			if err := runHooks(newHookValues(v.Location, v.values(), v.Duration)); err != nil {
+				log.Printf("%v\n", err)
+				failedHooks++
 			}
 		}
 	}
 
+	if failedHooks > 0 {
+		return fmt.Errorf("the hooks of %d videos failed", failedHooks)
+	}
 	return nil
 }
 
-func downloadVideo(v *UpVideoInfo) (*UpVideoInfo, bool, error) {
-	folder := path.Join(getUPerVideosListFolderLocation(v.Author), v.Title)
-	err := os.MkdirAll(folder, os.ModePerm)
+// planUPerVideos resolves the streams, outputs and sizes of the videos without writing to the disk.
+// The outputs are renamed like the download renames them, the archived videos are skipped with their location as output.
+func planUPerVideos(videos []*UpVideoInfo) (*Plan, error) {
+	plan := &Plan{}
+	planned := make(map[string]bool)
+	for _, v := range videos {
+		if isCanceled() {
+			return nil, ctx.Err()
+		}
+		item := PlanItem{
+			Action: planActionSkip,
+			BvID:   v.BvID,
+			CID:    v.CID,
+			Title:  v.Title,
+			Part:   v.Part,
+		}
+		if v.Location != "" {
+			item.Reason = "downloaded to " + v.Location
+			plan.add(item)
+			continue
+		}
+		cache := newPlayUrlCache(v.BvID, v.CID, 0, bilibili.FnvalDashAll, nil)
+		playUrlResp, err := cache.get(false)
+		if err != nil {
+			item.Reason = fmt.Sprintf("playurl failed: %v", err)
+			plan.add(item)
+			continue
+		}
+		if v.VideoQuality == 0 {
+			setStreams(v, playUrlResp)
+		}
+		item.quality = archiveQuality(v, playUrlResp)
+		if location, ok := lookupArchived(v.AID, v.CID, item.quality); ok {
+			item.Reason = "in the download archive, use --force to download it again"
+			item.Output = location
+			item.video = v
+			plan.add(item)
+			continue
+		}
+		item.Action = planActionDownload
+		item.video, item.cache = v, cache
+		fillPlanItem(&item, v, playUrlResp)
+		// the urls expire long before the last videos of a big list start, so they are fetched again by the download
+		cache.forget()
+		output, err := resolveOutput(getUPerVideosListFolderLocation(v.Author), uperTemplate(v), v.values(), ".mp4")
+		if err != nil {
+			return nil, err
+		}
+		// the videos are downloaded in order, so an output may be taken by an earlier one
+		item.Output = filename.UniqueReserved(output, planned)
+		planned[item.Output] = true
+		plan.add(item)
+	}
+	return plan, nil
+}
+
+// archiveQuality is the quality of the video stream, the resolved quality of the segments for the videos without dash,
+// so they are recorded like download does.
+func archiveQuality(v *UpVideoInfo, playUrlResp *bilibili.PlayUrlResp) bilibili.Qn {
+	if v.VideoQuality != 0 {
+		return v.VideoQuality
+	}
+	return bilibili.Qn(playUrlResp.Data.Quality)
+}
+
+// fillPlanItem sets the streams and size of the video.
+func fillPlanItem(item *PlanItem, v *UpVideoInfo, playUrlResp *bilibili.PlayUrlResp) {
+	if v.VideoQuality != 0 {
+		item.VideoQuality = playUrlResp.QnDescription(v.VideoQuality)
+		item.AudioQuality = playUrlResp.QnDescription(v.AudioQuality)
+		item.Codec = chooseDashVideo(playUrlResp, v.VideoQuality, v.VideoCodec).CodecName()
+		item.Size = streamSize(playUrlResp, v.VideoQuality) + streamSize(playUrlResp, v.AudioQuality)
+	} else {
+		item.VideoQuality = playUrlResp.QnDescription(bilibili.Qn(playUrlResp.Data.Quality))
+		item.Size = segmentsSize(playUrlResp)
+	}
+}
+
+func downloadVideo(v *UpVideoInfo, cache *playUrlCache) (*UpVideoInfo, bool, error) {
+	file, err := uperOutputPath(v)
 	if err != nil {
-		log.Printf("MkdirAll %s failed: %v\n", folder, err)
+		log.Printf("Create output path of %s failed: %v\n", v.Title, err)
 		return v, false, err
 	}
-	fileName := v.Part + ".mp4"
-	file := filepath.Join(folder, fileName)
 
-	writer, err := getDownloadDestFile(folder, file)
+	sources, err := cache.segmentSources()
 	if err != nil {
-		return nil, false, err
+		log.Printf("Get the segments of %s failed: %v\n", v.Title, err)
+		return v, false, err
 	}
-	defer func(writer *os.File) {
-		err := writer.Close()
-		if err != nil {
-			panic(err)
-		}
-	}(writer)
 
 	fmt.Printf("Download then video of %s directly.\n", v.Title)
-	err = downloadMedia("Video", v.DownloadURL, writer)
+	err = downloadSegments(sources, file, uperExpected(cache))
 	if err != nil {
 		return nil, false, err
 	}
+	if !PathExists(file) {
+		// the segments are kept as they are
+		return v, false, nil
+	}
+	embedMetadata(file, uperTags(v))
+
+	v.Location = file
 
 	return v, true, nil
 }
 
-func downloadAndMergeVideo(v *UpVideoInfo) (*UpVideoInfo, bool, error) {
-	folder := path.Join(getUPerVideosListFolderLocation(v.Author), v.Title)
-	err := os.MkdirAll(folder, os.ModePerm)
+func downloadAndMergeVideo(v *UpVideoInfo, cache *playUrlCache) (*UpVideoInfo, bool, error) {
+	file, err := uperOutputPath(v)
 	if err != nil {
-		log.Printf("MkdirAll %s failed: %v\n", folder, err)
+		log.Printf("Create output path of %s failed: %v\n", v.Title, err)
 		return v, false, err
 	}
-	file := filepath.Join(folder, v.Part+"["+v.VideoQuality.String()+","+v.AudioQuality.String()+"].mp4")
+	folder := filepath.Dir(file)
+	expected := uperExpected(cache)
 
 	videoTmp, err := os.CreateTemp(folder, "bilibili_video_*.m4s")
 	if err != nil {
@@ -97,7 +233,7 @@ func downloadAndMergeVideo(v *UpVideoInfo) (*UpVideoInfo, bool, error) {
 		return v, false, err
 	}
 
-	audioTmp, err := os.CreateTemp(outputDir, "bilibili_audio_*.m4s")
+	audioTmp, err := os.CreateTemp(folder, "bilibili_audio_*.m4s")
 	if err != nil {
 		log.Printf("CreateTemp audioTmp failed: %v\n", err)
 		return v, false, err
@@ -115,24 +251,72 @@ func downloadAndMergeVideo(v *UpVideoInfo) (*UpVideoInfo, bool, error) {
 	}()
 
 	fmt.Printf("Downloading %s video of %s\n", v.VideoQuality.String(), v.Title)
-	if err = downloadMedia("Video", v.VideoURL, videoTmp); err != nil {
+	if err = downloadVerifiedMedia("Video", cache.dashVideoSource(v.VideoQuality, v.VideoCodec), videoTmp, expected); err != nil {
 		log.Printf("download video failed: %v\n", err)
 		return v, false, err
 	}
 	fmt.Printf("Downloading %s audio of %s\n", v.AudioQuality.String(), v.Title)
-	if err = downloadMedia("Audio", v.AudioURL, audioTmp); err != nil {
+	if err = downloadVerifiedMedia("Audio", cache.dashAudioSource(v.AudioQuality), audioTmp, expected); err != nil {
 		log.Printf("download audio failed: %v\n", err)
 		return v, false, err
 	}
 	ins.Start()
 	defer ins.Stop()
-	f, err := merge(videoTmp.Name(), audioTmp.Name(), file)
+	tags := uperTags(v)
+	cover := downloadTagsCover(tags, folder)
+	if len(cover) != 0 {
+		defer os.Remove(cover)
+	}
+	f, err := merge(videoTmp.Name(), audioTmp.Name(), file, tags, cover)
 	if err != nil {
 		log.Printf("merge video and audio failed: %v\n", err)
 		return v, false, err
 	}
+	if err = verifyMedia(f, expected); err != nil {
+		log.Printf("%s fails the verification: %v\n", f, err)
+		return v, false, err
+	}
 
 	v.Location = f
 
 	return v, true, nil
 }
+
+// uperExpected is the duration of the page the downloads are verified against, UpVideoInfo.Duration is the one of the whole video.
+// It is zero when the playurl is unavailable, which skips the check.
+func uperExpected(cache *playUrlCache) time.Duration {
+	resp, err := cache.get(false)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(resp.Data.Timelength) * time.Millisecond
+}
+
+// uperTags are the tags of the video info, only the title and uploader are tagged when it is unavailable.
+func uperTags(v *UpVideoInfo) mediaTags {
+	info, err := getVideoInfo(v.BvID)
+	if err == nil {
+		for _, page := range info.Pages {
+			if page.CID == v.CID {
+				tags := videoTags(info, page)
+				tags.Chapters = viewPointChapters(v.BvID, v.CID)
+				return tags
+			}
+		}
+	}
+	return mediaTags{Title: v.Title, Artist: v.values().Uploader}
+}
+
+func uperOutputPath(v *UpVideoInfo) (string, error) {
+	return expandOutput(getUPerVideosListFolderLocation(v.Author), uperTemplate(v), v.values(), ".mp4")
+}
+
+func uperTemplate(v *UpVideoInfo) string {
+	if len(uperOutputTemplate) != 0 {
+		return uperOutputTemplate
+	}
+	if v.VideoQuality != 0 {
+		return "{title}/{part}[{quality},{audio_quality}].mp4"
+	}
+	return "{title}/{part}.mp4"
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/misssonder/bilibili/pkg/video"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+const (
+	streamKindVideo = "video"
+	streamKindAudio = "audio"
+	streamKindDurl  = "durl"
+)
+
+type StreamFormat struct {
+	Kind       string
+	ID         int
+	Quality    string
+	Codec      string
+	Resolution string
+	FrameRate  string
+	Bandwidth  int
+	Size       int64
+	Segments   int
+	Mirrors    []string
+}
+
+type Formats struct {
+	BvID     string
+	CID      int64
+	Title    string
+	Duration time.Duration
+	Formats  []StreamFormat
+}
+
+var formatsPage int
+
+var formatsCmd = &cobra.Command{
+	Use:   "formats",
+	Short: "List the available streams of video.",
+	Args:  cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkOutputFormat(); err != nil {
+			return err
+		}
+		return login()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		formats, err := getFormats(args[0], formatsPage)
+		exitOnError(err)
+		exitOnError(writeOutput(os.Stdout, formats, func(w io.Writer) {
+			writeFormatsOutput(w, formats)
+		}))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(formatsCmd)
+	addFormatFlag(formatsCmd.Flags())
+	formatsCmd.Flags().IntVarP(&formatsPage, "page", "p", 1, "The page of video or the episode of season.")
+}
+
+func getFormats(id string, page int) (*Formats, error) {
+	formats := &Formats{}
+	if video.IsSSID(id) || video.IsEpID(id) {
+		info, err := getSeasonInfo(id)
+		if err != nil {
+			return nil, err
+		}
+		if page < 1 || page > len(info.Episodes) {
+			return nil, fmt.Errorf("episode %d is out of range [1, %d]", page, len(info.Episodes))
+		}
+		episode := info.Episodes[page-1]
+		formats.BvID, formats.CID, formats.Title = episode.BvID, episode.CID, episode.Title
+	} else {
+		info, err := getVideoInfo(id)
+		if err != nil {
+			return nil, err
+		}
+		if page < 1 || page > len(info.Pages) {
+			return nil, fmt.Errorf("page %d is out of range [1, %d]", page, len(info.Pages))
+		}
+		formats.BvID, formats.CID, formats.Title = info.BvID, info.Pages[page-1].CID, info.Title
+		if len(info.Pages) > 1 {
+			formats.Title = info.Pages[page-1].Part
+		}
+	}
+
+	playUrlResp, err := client.PlayUrl(formats.BvID, formats.CID, 0, bilibili.FnvalDashAll)
+	if err != nil {
+		return nil, err
+	}
+	formats.Duration = time.Duration(playUrlResp.Data.Timelength) * time.Millisecond
+	formats.Formats = append(formats.Formats, dashFormats(playUrlResp)...)
+
+	durlFormats, err := getDurlFormats(formats.BvID, formats.CID)
+	if err != nil {
+		return nil, err
+	}
+	formats.Formats = append(formats.Formats, durlFormats...)
+	return formats, nil
+}
+
+func dashFormats(playUrlResp *bilibili.PlayUrlResp) []StreamFormat {
+	duration := time.Duration(playUrlResp.Data.Timelength) * time.Millisecond
+	formats := make([]StreamFormat, 0)
+	for _, v := range playUrlResp.Data.Dash.Video {
+		formats = append(formats, StreamFormat{
+			Kind:       streamKindVideo,
+			ID:         v.ID,
+			Quality:    playUrlResp.QnDescription(bilibili.Qn(v.ID)),
+			Codec:      v.Codecs,
+			Resolution: fmt.Sprintf("%dx%d", v.Width, v.Height),
+			FrameRate:  v.FrameRate,
+			Bandwidth:  v.Bandwidth,
+			Size:       estimateSize(v.Bandwidth, duration),
+			Mirrors:    append([]string{v.BaseURL}, v.BackupURL...),
+		})
+	}
+	for _, a := range playUrlResp.AudioStreams() {
+		formats = append(formats, StreamFormat{
+			Kind:      streamKindAudio,
+			ID:        a.ID,
+			Quality:   playUrlResp.QnDescription(bilibili.Qn(a.ID)),
+			Codec:     a.Codecs,
+			Bandwidth: a.Bandwidth,
+			Size:      estimateSize(a.Bandwidth, duration),
+			Mirrors:   append([]string{a.BaseURL}, a.BackupURL...),
+		})
+	}
+	return formats
+}
+
+// getDurlFormats requests the durl of every accepted quality, a quality the server downgrades is skipped.
+func getDurlFormats(bvID string, cid int64) ([]StreamFormat, error) {
+	playUrlResp, err := client.PlayUrl(bvID, cid, bilibili.Qn4k, bilibili.FnvalMP4)
+	if err != nil {
+		return nil, err
+	}
+	formats := make([]StreamFormat, 0, len(playUrlResp.Data.AcceptQuality))
+	seen := make(map[int]bool)
+	for _, qn := range playUrlResp.Data.AcceptQuality {
+		resp := playUrlResp
+		if qn != playUrlResp.Data.Quality {
+			if resp, err = client.PlayUrl(bvID, cid, bilibili.Qn(qn), bilibili.FnvalMP4); err != nil {
+				return nil, err
+			}
+		}
+		if seen[resp.Data.Quality] || len(resp.Data.Durl) == 0 {
+			continue
+		}
+		seen[resp.Data.Quality] = true
+		format := StreamFormat{
+			Kind:     streamKindDurl,
+			ID:       resp.Data.Quality,
+			Quality:  resp.QnDescription(bilibili.Qn(resp.Data.Quality)),
+			Codec:    resp.Data.Format,
+			Size:     segmentsSize(resp),
+			Segments: len(resp.Data.Durl),
+		}
+		for _, durl := range resp.Data.Durl {
+			format.Mirrors = append(format.Mirrors, durl.URL)
+			format.Mirrors = append(format.Mirrors, durl.BackupURL...)
+		}
+		if resp.Data.Timelength > 0 {
+			format.Bandwidth = int(float64(format.Size) * 8 / (time.Duration(resp.Data.Timelength) * time.Millisecond).Seconds())
+		}
+		formats = append(formats, format)
+	}
+	return formats, nil
+}
+
+func writeFormatsOutput(w io.Writer, formats *Formats) {
+	fmt.Fprintln(w, "Title:      ", formats.Title)
+	fmt.Fprintln(w, "BvID:       ", formats.BvID)
+	fmt.Fprintln(w, "CID:        ", formats.CID)
+	fmt.Fprintln(w, "Duration:   ", timeString(formats.Duration))
+	fmt.Fprintln(w)
+	table := tablewriter.NewWriter(w)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{
+		"kind",
+		"id",
+		"quality",
+		"codec",
+		"resolution",
+		"fps",
+		"bandwidth",
+		"size",
+		"mirrors",
+	})
+	for _, format := range formats.Formats {
+		table.Append([]string{
+			format.Kind,
+			strconv.Itoa(format.ID),
+			format.Quality,
+			format.Codec,
+			format.Resolution,
+			format.FrameRate,
+			fmt.Sprintf("%d kbps", format.Bandwidth/1000),
+			"~" + formatSize(format.Size),
+			strings.Join(mirrorHosts(format.Mirrors), ","),
+		})
+	}
+	table.Render()
+}
+
+// mirrorHosts are the distinct hosts of urls.
+func mirrorHosts(urls []string) []string {
+	hosts := make([]string, 0, len(urls))
+	seen := make(map[string]bool)
+	for _, u := range urls {
+		parsed, err := url.Parse(u)
+		if err != nil || seen[parsed.Host] {
+			continue
+		}
+		seen[parsed.Host] = true
+		hosts = append(hosts, parsed.Host)
+	}
+	return hosts
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDashFormats(t *testing.T) {
+	resp := &bilibili.PlayUrlResp{}
+	resp.Data.Timelength = 10_000
+	resp.Data.Dash.Video = []bilibili.DashVideo{{ID: 80, Codecs: "avc1.640032", Width: 1920, Height: 1080, FrameRate: "30", Bandwidth: 800_000, BaseURL: "https://a.example/v.m4s", BackupURL: []string{"https://b.example/v.m4s"}}}
+	resp.Data.Dash.Audio = []bilibili.DashAudio{{ID: 30280, Codecs: "mp4a.40.2", Bandwidth: 160_000, BaseURL: "https://a.example/a.m4s"}}
+
+	formats := dashFormats(resp)
+	assert.Len(t, formats, 2)
+	assert.Equal(t, streamKindVideo, formats[0].Kind)
+	assert.Equal(t, "1920x1080", formats[0].Resolution)
+	assert.Equal(t, int64(1_000_000), formats[0].Size)
+	assert.Equal(t, []string{"a.example", "b.example"}, mirrorHosts(formats[0].Mirrors))
+	assert.Equal(t, streamKindAudio, formats[1].Kind)
+	assert.Equal(t, int64(200_000), formats[1].Size)
+}
+
+func TestWriteFormatsOutput(t *testing.T) {
+	var buf bytes.Buffer
+	writeFormatsOutput(&buf, &Formats{BvID: "BV1xx", CID: 1, Title: "title"})
+	// the header goes to the writer as well as the table
+	assert.True(t, strings.HasPrefix(buf.String(), "Title:       title\n"))
+	assert.Contains(t, buf.String(), "KIND")
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kballard/go-shellquote"
+	"github.com/misssonder/bilibili/internal/filename"
+	"github.com/spf13/pflag"
+)
+
+var (
+	execHooks   []string
+	execTimeout time.Duration
+)
+
+// hookValues are the template variables of the hook commands.
+type hookValues struct {
+	Path     string
+	BvID     string
+	Title    string
+	Uploader string
+	Duration time.Duration
+}
+
+func newHookValues(path string, values filename.Values, duration time.Duration) hookValues {
+	return hookValues{Path: path, BvID: values.BvID, Title: values.Title, Uploader: values.Uploader, Duration: duration}
+}
+
+func addHookFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringArrayVar(&execHooks, "exec", nil, "The command run after each successful download, supports {path} {bvid} {title} {uploader} {duration}, can be repeated")
+	flagSet.DurationVar(&execTimeout, "exec-timeout", 10*time.Minute, "The timeout of each --exec command, 0 disables it")
+}
+
+func checkHooks() error {
+	for _, hook := range execHooks {
+		args, err := shellquote.Split(hook)
+		if err != nil {
+			return fmt.Errorf("invalid --exec %q: %w", hook, err)
+		}
+		if len(args) == 0 {
+			return fmt.Errorf("empty --exec command")
+		}
+	}
+	return nil
+}
+
+// expand replaces the variables of every argument, the arguments are split before,
+// so the values are passed as they are without quoting.
+func (values hookValues) expand(args []string) []string {
+	replacer := strings.NewReplacer(
+		"{path}", values.Path,
+		"{bvid}", values.BvID,
+		"{title}", values.Title,
+		"{uploader}", values.Uploader,
+		"{duration}", strconv.Itoa(int(values.Duration.Seconds())),
+	)
+	expanded := make([]string, 0, len(args))
+	for _, arg := range args {
+		expanded = append(expanded, replacer.Replace(arg))
+	}
+	return expanded
+}
+
+func (values hookValues) environ() []string {
+	return append(os.Environ(),
+		"BILIBILI_PATH="+values.Path,
+		"BILIBILI_BVID="+values.BvID,
+		"BILIBILI_TITLE="+values.Title,
+		"BILIBILI_UPLOADER="+values.Uploader,
+		"BILIBILI_DURATION="+strconv.Itoa(int(values.Duration.Seconds())),
+	)
+}
+
+// runHooks runs the --exec commands in order, it stops at the first failed one.
+func runHooks(values hookValues) error {
+	for _, hook := range execHooks {
+		if err := runHook(hook, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runHook(hook string, values hookValues) error {
+	args, err := shellquote.Split(hook)
+	if err != nil {
+		return err
+	}
+	args = values.expand(args)
+	hookCtx := ctx
+	if execTimeout > 0 {
+		var cancel context.CancelFunc
+		hookCtx, cancel = context.WithTimeout(ctx, execTimeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(hookCtx, args[0], args[1:]...)
+	cmd.Env = values.environ()
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	switch {
+	case err == nil:
+		return nil
+	case hookCtx.Err() == context.DeadlineExceeded:
+		return fmt.Errorf("hook %q of %s timed out after %s", hook, values.Path, execTimeout)
+	default:
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return fmt.Errorf("hook %q of %s exited with code %d", hook, values.Path, exitErr.ExitCode())
+		}
+		return fmt.Errorf("hook %q of %s failed: %w", hook, values.Path, err)
+	}
+}
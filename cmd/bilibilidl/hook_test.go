@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hooks run sh")
+	}
+	defer func(hooks []string, timeout time.Duration) { execHooks, execTimeout = hooks, timeout }(execHooks, execTimeout)
+	out := filepath.Join(t.TempDir(), "out.txt")
+	values := hookValues{Path: "/videos/a b.mp4", BvID: "BV1", Title: "it's", Duration: 90 * time.Second}
+
+	execHooks = []string{`sh -c 'printf "%s|%s|%s|%s|$BILIBILI_BVID" "$0" "$1" "$2" "$3" > ` + out + `' {path} {title} {duration} {bvid}`}
+	execTimeout = time.Second
+	assert.NoError(t, runHooks(values))
+	content, err := os.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Equal(t, "/videos/a b.mp4|it's|90|BV1|BV1", string(content))
+
+	execHooks = []string{"sh -c 'exit 3'"}
+	assert.EqualError(t, runHooks(values), `hook "sh -c 'exit 3'" of /videos/a b.mp4 exited with code 3`)
+
+	execHooks = []string{"sleep 5"}
+	execTimeout = 100 * time.Millisecond
+	assert.ErrorContains(t, runHooks(values), "timed out")
+}
@@ -30,16 +30,30 @@ type VideoInfo struct {
 	AID         int
 	Title       string
 	Author      string
+	Uploader    string
+	UploaderMid int
+	Cover       string
+	Genre       string
+	Staff       []Staff
 	Duration    time.Duration
 	PublishTime string
 	CreateTime  string
 	Description string
-	Pages       []Page
+	// SteinGate is set for the interactive videos, their pages are the root node only.
+	SteinGate bool
+	Pages     []Page
+}
+
+type Staff struct {
+	Mid   int
+	Name  string
+	Title string
 }
 
 type SeasonInfo struct {
 	SeasonID    int
 	Title       string
+	Cover       string
 	Duration    time.Duration
 	Description string
 	Episodes    []Episode
@@ -49,14 +63,16 @@ type Episode struct {
 	BvID      string
 	AID       int
 	CID       int64
+	Number    string
 	Title     string
+	Cover     string
 	Duration  time.Duration
 	Dimension Dimension
 }
 
 var infoCmd = &cobra.Command{
 	Use:   "info",
-	Short: "Show base info of video.",
+	Short: "Show base info of video, season, song (au) or audio menu (am).",
 	Args:  cobra.ExactArgs(1),
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		if err := checkOutputFormat(); err != nil {
@@ -65,7 +81,19 @@ var infoCmd = &cobra.Command{
 		return login()
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		if video.IsEpID(args[0]) || video.IsSSID(args[0]) {
+		if video.IsAuID(args[0]) {
+			songInfo, err := getSongInfo(args[0])
+			exitOnError(err)
+			exitOnError(writeOutput(os.Stdout, songInfo, func(w io.Writer) {
+				writeSongInfoOutput(w, songInfo)
+			}))
+		} else if video.IsAmID(args[0]) {
+			menuInfo, err := getMenuInfo(args[0])
+			exitOnError(err)
+			exitOnError(writeOutput(os.Stdout, menuInfo, func(w io.Writer) {
+				writeMenuInfoOutput(w, menuInfo)
+			}))
+		} else if video.IsEpID(args[0]) || video.IsSSID(args[0]) {
 			seasonInfo, err := getSeasonInfo(args[0])
 			exitOnError(err)
 			exitOnError(writeOutput(os.Stdout, seasonInfo, func(w io.Writer) {
@@ -103,6 +131,7 @@ func getSeasonInfo(id string) (seasonInfo *SeasonInfo, err error) {
 	seasonInfo = &SeasonInfo{
 		SeasonID:    info.Result.SeasonID,
 		Title:       fmt.Sprintf("%s(%s)", info.Result.Title, info.Result.Subtitle),
+		Cover:       info.Result.Cover,
 		Description: info.Result.Evaluate,
 		Episodes:    make([]Episode, 0),
 	}
@@ -112,7 +141,9 @@ func getSeasonInfo(id string) (seasonInfo *SeasonInfo, err error) {
 			CID:      int64(episode.Cid),
 			AID:      episode.Aid,
 			Duration: time.Duration(episode.Duration) * time.Millisecond,
+			Number:   episode.Title,
 			Title:    episode.LongTitle,
+			Cover:    episode.Cover,
 		}
 		if episode.Dimension.Rotate != 0 {
 			e.Dimension.Height = episode.Dimension.Width
@@ -141,11 +172,19 @@ func getVideoInfo(id string) (videoInfo *VideoInfo, err error) {
 		AID:         info.Data.Aid,
 		Title:       info.Data.Title,
 		Author:      fmt.Sprintf("%s(%d)", info.Data.Owner.Name, info.Data.Owner.Mid),
+		Uploader:    info.Data.Owner.Name,
+		UploaderMid: info.Data.Owner.Mid,
+		Cover:       info.Data.Pic,
+		Genre:       info.Data.Tname,
 		PublishTime: time.Unix(int64(info.Data.Pubdate), 0).Format(time.RFC3339),
 		CreateTime:  time.Unix(int64(info.Data.Ctime), 0).Format(time.RFC3339),
 		Description: info.Data.Desc,
+		SteinGate:   info.Data.Rights.IsSteinGate == 1,
 		Pages:       make([]Page, 0),
 	}
+	for _, staff := range info.Data.Staff {
+		videoInfo.Staff = append(videoInfo.Staff, Staff{Mid: staff.Mid, Name: staff.Name, Title: staff.Title})
+	}
 	for _, p := range info.Data.Pages {
 		page := Page{
 			CID:      int64(p.Cid),
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/misssonder/bilibili/pkg/ratelimit"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	liveProtocolFLV = "flv"
+	liveProtocolHLS = "hls"
+)
+
+var (
+	liveOutputDir     string
+	liveOutputFile    string
+	liveQuality       int
+	liveProtocol      string
+	liveCodec         string
+	liveSplitSize     string
+	liveSplitDuration time.Duration
+	liveRetries       int
+	liveRetryInterval time.Duration
+	liveWatchInterval time.Duration
+	liveChat          bool
+	// liveChatUid is the uid of the login, the names of the chat senders are masked without it.
+	// It is resolved once before the recordings start, the chats of all rooms share it.
+	liveChatUid int64
+)
+
+var liveCmd = &cobra.Command{
+	Use:   "live",
+	Short: "Record live rooms.",
+}
+
+var liveRecordCmd = &cobra.Command{
+	Use:   "record <room>",
+	Short: "Record a live room until the live ends or Ctrl-C, the room is the short or real id or the url of it.",
+	Args:  cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkLiveFlags(); err != nil {
+			return err
+		}
+		// the cookie is optional, it unlocks the higher qualities
+		initLiveLogin()
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := parseRoomID(args[0])
+		exitOnError(err)
+		exitOnError(recordLive(id))
+	},
+}
+
+var liveWatchCmd = &cobra.Command{
+	Use:   "watch <room>...",
+	Short: "Watch live rooms, record a room whenever it goes live until the live ends, stop with Ctrl-C.",
+	Args:  cobra.MinimumNArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkLiveFlags(); err != nil {
+			return err
+		}
+		if liveWatchInterval <= 0 {
+			return fmt.Errorf("invalid watch interval: %s", liveWatchInterval)
+		}
+		initLiveLogin()
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ids := make([]int64, 0, len(args))
+		seen := make(map[int64]bool)
+		for _, arg := range args {
+			id, err := parseRoomID(arg)
+			exitOnError(err)
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		watcher := &liveWatcher{
+			ctx:      ctx,
+			ids:      ids,
+			interval: liveWatchInterval,
+			isLive:   isRoomLive,
+			record:   recordLive,
+		}
+		watcher.watch()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(liveCmd)
+	liveCmd.AddCommand(liveRecordCmd)
+	liveCmd.AddCommand(liveWatchCmd)
+	addLiveRecordFlags(liveRecordCmd.Flags())
+	addLiveRecordFlags(liveWatchCmd.Flags())
+	liveWatchCmd.Flags().DurationVar(&liveWatchInterval, "interval", time.Minute, "The interval between the checks of the room status.")
+}
+
+func addLiveRecordFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringVarP(&liveOutputDir, "directory", "d", ".", "The output directory.")
+	flagSet.StringVarP(&liveOutputFile, "filename", "o", "{room} {uploader} {start}", "The output file, supports templates with {room} {title} {uploader} {mid} {start} (the start of the broadcast) {quality} and {page} for the split parts.")
+	flagSet.IntVarP(&liveQuality, "quality", "q", 0, "The live quality like 10000 for 原画, 400 for 蓝光 or 250 for 超清 (default the highest).")
+	flagSet.StringVar(&liveProtocol, "protocol", liveProtocolFLV, "The stream protocol (flv/hls).")
+	flagSet.StringVar(&liveCodec, "codec", "avc", "The preferred video codec (avc/hevc).")
+	flagSet.StringVar(&liveSplitSize, "split-size", "", "Start a new file when the file reaches the size, e.g. 2G.")
+	flagSet.DurationVar(&liveSplitDuration, "split-duration", 0, "Start a new file when the file reaches the duration, e.g. 1h.")
+	flagSet.IntVar(&liveRetries, "retries", 30, "Give up after the stream fails to reconnect so many times in a row.")
+	flagSet.DurationVar(&liveRetryInterval, "retry-interval", 5*time.Second, "The interval between the reconnections.")
+	flagSet.BoolVar(&liveChat, "chat", false, "Save the chat alongside the files: the danmaku, gifts, super chats and interactions as json lines and the danmaku as xml.")
+}
+
+// initLiveLogin loads the cookie if any, and the uid of it for the chat.
+func initLiveLogin() {
+	if !isLogin() || !liveChat {
+		return
+	}
+	if info, err := client.NavInfo(); err == nil && info.Data.IsLogin {
+		liveChatUid = int64(info.Data.Mid)
+	}
+}
+
+func checkLiveFlags() error {
+	if liveProtocol != liveProtocolFLV && liveProtocol != liveProtocolHLS {
+		return fmt.Errorf("invalid live protocol: %s", liveProtocol)
+	}
+	if liveCodec != "avc" && liveCodec != "hevc" {
+		return fmt.Errorf("invalid live codec: %s", liveCodec)
+	}
+	_, err := ratelimit.ParseRate(liveSplitSize)
+	return err
+}
+
+var roomUrlRegexp = regexp.MustCompile(`live\.bilibili\.com/(?:h5/|blanc/)?(\d+)`)
+
+// parseRoomID parses the room id of a number or a url like https://live.bilibili.com/21452505.
+func parseRoomID(s string) (int64, error) {
+	if match := roomUrlRegexp.FindStringSubmatch(s); match != nil {
+		s = match[1]
+	}
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid live room: %s", s)
+	}
+	return id, nil
+}
+
+// liveRoom is a room resolved to its real id.
+type liveRoom struct {
+	ID         int64
+	Uid        int64
+	Title      string
+	Uploader   string
+	LiveStatus int
+	// LiveStart is when the broadcast starts, it is {start} of the recorded files.
+	LiveStart time.Time
+}
+
+func getLiveRoom(id int64) (*liveRoom, error) {
+	roomInit, err := client.RoomInit(id)
+	if err != nil {
+		return nil, err
+	}
+	room := &liveRoom{
+		ID:         roomInit.Data.RoomID,
+		Uid:        roomInit.Data.Uid,
+		LiveStatus: roomInit.Data.LiveStatus,
+	}
+	if roomInit.Data.LiveTime > 0 {
+		room.LiveStart = time.Unix(roomInit.Data.LiveTime, 0)
+	}
+	info, err := client.RoomInfo(room.ID)
+	if err != nil {
+		return nil, err
+	}
+	room.Title = info.Data.Title
+	if room.LiveStart.IsZero() {
+		room.LiveStart = bilibili.LiveStartTime(info.Data.LiveTime)
+	}
+	// the name only names the files, the uid stands for it when it is unavailable
+	room.Uploader = strconv.FormatInt(room.Uid, 10)
+	if master, err := client.MasterInfo(room.Uid); err == nil && len(master.Data.Info.Uname) != 0 {
+		room.Uploader = master.Data.Info.Uname
+	}
+	return room, nil
+}
+
+// recordLive records the room of id until the live ends or ctx is canceled.
+func recordLive(id int64) error {
+	room, err := getLiveRoom(id)
+	if err != nil {
+		return err
+	}
+	if room.LiveStatus != bilibili.LiveStatusLive {
+		return fmt.Errorf("room %d is not live", room.ID)
+	}
+	recorder, err := newLiveRecorder(ctx, room)
+	if err != nil {
+		return err
+	}
+	if liveChat {
+		chatCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		recorder.chat = &chatRecorder{roomID: room.ID}
+		go func() {
+			defer close(done)
+			recordChat(chatCtx, room.ID, recorder.chat)
+		}()
+		defer func() {
+			cancel()
+			<-done
+		}()
+	}
+	return recorder.record()
+}
+
+func isRoomLive(id int64) (bool, error) {
+	roomInit, err := client.RoomInit(id)
+	if err != nil {
+		return false, err
+	}
+	return roomInit.Data.LiveStatus == bilibili.LiveStatusLive, nil
+}
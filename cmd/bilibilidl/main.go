@@ -1,14 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	bilibili "github.com/misssonder/bilibili/pkg/client"
 )
 
 var (
 	client *bilibili.Client
+	// ctx is canceled on SIGINT or SIGTERM, it stops the running downloads and ffmpeg.
+	ctx = context.Background()
 )
 
 func init() {
@@ -16,6 +21,13 @@ func init() {
 }
 
 func main() {
+	var stop context.CancelFunc
+	ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		// a second signal kills the process at once
+		stop()
+	}()
 	exitOnError(rootCmd.Execute())
 }
 
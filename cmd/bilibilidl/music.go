@@ -0,0 +1,399 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/misssonder/bilibili/internal/filename"
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/misssonder/bilibili/pkg/video"
+	"github.com/olekukonko/tablewriter"
+)
+
+// SongInfo is a song of the music area, Author is the singer and BvID the video it comes from, if any.
+type SongInfo struct {
+	AuID        int64
+	Title       string
+	Author      string
+	Uploader    string
+	UploaderMid int
+	Cover       string
+	Intro       string
+	Duration    time.Duration
+	PublishTime string
+	BvID        string
+}
+
+// MenuInfo is an audio menu, the playlist of songs.
+type MenuInfo struct {
+	AmID        int64
+	Title       string
+	Uploader    string
+	UploaderMid int
+	Cover       string
+	Intro       string
+	Duration    time.Duration
+	Songs       []SongInfo
+}
+
+func newSongInfo(song bilibili.AudioSong) SongInfo {
+	info := SongInfo{
+		AuID:        song.Id,
+		Title:       song.Title,
+		Author:      song.Author,
+		Uploader:    song.Uname,
+		UploaderMid: song.Uid,
+		Cover:       song.Cover,
+		Intro:       song.Intro,
+		Duration:    time.Duration(song.Duration) * time.Second,
+		BvID:        song.Bvid,
+	}
+	if song.Passtime > 0 {
+		info.PublishTime = time.Unix(song.Passtime, 0).Format(time.RFC3339)
+	}
+	return info
+}
+
+func getSongInfo(id string) (*SongInfo, error) {
+	auID, err := video.ExtractAuID(id)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.AudioSongInfo(auID)
+	if err != nil {
+		return nil, err
+	}
+	info := newSongInfo(resp.Data)
+	return &info, nil
+}
+
+func getMenuInfo(id string) (*MenuInfo, error) {
+	amID, err := video.ExtractAmID(id)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.AudioMenuInfo(amID)
+	if err != nil {
+		return nil, err
+	}
+	songs, err := client.AudioMenuSongs(amID)
+	if err != nil {
+		return nil, err
+	}
+	info := &MenuInfo{
+		AmID:        resp.Data.MenuId,
+		Title:       resp.Data.Title,
+		Uploader:    resp.Data.Uname,
+		UploaderMid: resp.Data.Uid,
+		Cover:       resp.Data.Cover,
+		Intro:       resp.Data.Intro,
+		Songs:       make([]SongInfo, 0, len(songs)),
+	}
+	for _, song := range songs {
+		info.Songs = append(info.Songs, newSongInfo(song))
+		info.Duration += info.Songs[len(info.Songs)-1].Duration
+	}
+	return info, nil
+}
+
+func writeSongInfoOutput(w io.Writer, info *SongInfo) {
+	fmt.Fprintln(w, "Title:      ", info.Title)
+	fmt.Fprintln(w, "Author:     ", info.Author)
+	fmt.Fprintln(w, "Uploader:   ", fmt.Sprintf("%s(%d)", info.Uploader, info.UploaderMid))
+	fmt.Fprintln(w, "Duration:   ", timeString(info.Duration))
+	fmt.Fprintln(w, "AuID:       ", info.AuID)
+	if len(info.BvID) != 0 {
+		fmt.Fprintln(w, "BvID:       ", info.BvID)
+	}
+	fmt.Fprintln(w, "Description:", info.Intro)
+}
+
+func writeMenuInfoOutput(w io.Writer, info *MenuInfo) {
+	fmt.Fprintln(w, "Title:      ", info.Title)
+	fmt.Fprintln(w, "Uploader:   ", fmt.Sprintf("%s(%d)", info.Uploader, info.UploaderMid))
+	fmt.Fprintln(w, "Duration:   ", timeString(info.Duration))
+	fmt.Fprintln(w, "AmID:       ", info.AmID)
+	fmt.Fprintln(w, "Description:", info.Intro)
+	fmt.Fprintln(w)
+	table := tablewriter.NewWriter(w)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{
+		"index",
+		"title",
+		"author",
+		"auid",
+		"duration",
+	})
+	for i, song := range info.Songs {
+		table.Append([]string{
+			strconv.Itoa(i + 1),
+			song.Title,
+			song.Author,
+			strconv.FormatInt(song.AuID, 10),
+			timeString(song.Duration),
+		})
+	}
+	table.Render()
+}
+
+func songValues(info *SongInfo, album string, track int) filename.Values {
+	values := filename.Values{
+		Title:    info.Title,
+		Part:     info.Title,
+		Page:     track,
+		BvID:     info.BvID,
+		Uploader: info.Uploader,
+		Mid:      info.UploaderMid,
+		AuID:     info.AuID,
+		Album:    album,
+	}
+	if pubdate, err := time.Parse(time.RFC3339, info.PublishTime); err == nil {
+		values.Pubdate = pubdate
+	}
+	return values
+}
+
+func songTags(info *SongInfo, album string) mediaTags {
+	tags := mediaTags{
+		Title:   info.Title,
+		Artist:  info.Author,
+		Album:   album,
+		Cover:   info.Cover,
+		Comment: fmt.Sprintf("https://www.bilibili.com/audio/au%d", info.AuID),
+	}
+	if len(tags.Artist) == 0 {
+		tags.Artist = info.Uploader
+	}
+	if len(info.Intro) != 0 {
+		tags.Comment = info.Intro + "\n\n" + tags.Comment
+	}
+	if pubdate, err := time.Parse(time.RFC3339, info.PublishTime); err == nil {
+		tags.Date = pubdate.Format("2006-01-02")
+	}
+	return tags
+}
+
+// downloadMusic downloads the song of an au id or all songs of an am id,
+// format is the audio format to convert to, the format of the stream is kept when it is empty.
+func downloadMusic(id, format string) error {
+	if video.IsAuID(id) {
+		info, err := getSongInfo(id)
+		if err != nil {
+			return err
+		}
+		quality, err := selectSongQuality(info.AuID)
+		if err != nil {
+			return err
+		}
+		return downloadSong(info, quality, outputTemplate(), format, songValues(info, "", 1))
+	}
+
+	info, err := getMenuInfo(id)
+	if err != nil {
+		return err
+	}
+	rows := make([]string, 0, len(bilibili.AudioQualities))
+	for _, quality := range bilibili.AudioQualities {
+		rows = append(rows, quality.String())
+	}
+	selected, err := selectList("Please select audio quality, a lower one is used when it is not available", rows)
+	if err != nil {
+		return err
+	}
+	template := outputFile
+	if len(template) == 0 {
+		template = "{album}/{page} {title}"
+	}
+	fmt.Printf("Downloading %d songs of %s\n", len(info.Songs), info.Title)
+	failed := 0
+	for i := range info.Songs {
+		if isCanceled() {
+			return ctx.Err()
+		}
+		song := &info.Songs[i]
+		values := songValues(song, info.Title, i+1)
+		if err = downloadSong(song, bilibili.AudioQualities[selected], template, format, values); err != nil {
+			fmt.Printf("Download au%d %s failed: %v\n", song.AuID, song.Title, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d songs failed", failed, len(info.Songs))
+	}
+	return nil
+}
+
+func selectSongQuality(auID int64) (bilibili.AudioQuality, error) {
+	resp, err := client.AudioStream(auID, bilibili.AudioQualityFLAC)
+	if err != nil {
+		return 0, err
+	}
+	qualities := resp.Data.Qualities
+	if len(qualities) == 0 {
+		return resp.Data.Type, nil
+	}
+	rows := make([]string, 0, len(qualities))
+	for _, quality := range qualities {
+		row := quality.Desc
+		if quality.Size > 0 {
+			row = fmt.Sprintf("%s (~%s)", row, formatSize(quality.Size))
+		}
+		if len(quality.RequireDesc) != 0 {
+			row += " " + quality.RequireDesc
+		}
+		rows = append(rows, row)
+	}
+	selected, err := selectList("Please select audio quality", rows)
+	if err != nil {
+		return 0, err
+	}
+	return qualities[selected].Type, nil
+}
+
+// downloadSong saves the song with its tags, and the lyrics and the cover beside it as name.lrc and name.jpg.
+// The song is recorded in the download archive with its au id and the requested quality, see songArchived.
+func downloadSong(info *SongInfo, quality bilibili.AudioQuality, template, format string, values filename.Values) error {
+	if songArchived(info.AuID, quality) {
+		return nil
+	}
+	stream, err := client.AudioStream(info.AuID, quality)
+	if err != nil {
+		return err
+	}
+	if len(stream.Data.Cdns) == 0 {
+		return fmt.Errorf("no stream of au%d: %s", info.AuID, stream.Data.Info)
+	}
+	if stream.Data.Type != quality {
+		fmt.Printf("au%d is downloaded in %s, %s is not available.\n", info.AuID, stream.Data.Type, quality)
+	}
+	ext := songStreamExt(stream.Data.Cdns[0])
+	if len(format) == 0 {
+		format = strings.TrimPrefix(ext, ".")
+	}
+	values.Quality = stream.Data.Type.String()
+	values.AudioQuality = values.Quality
+	output, err := expandOutput(outputDir, template, values, "."+format)
+	if err != nil {
+		return err
+	}
+	dir := path.Dir(output)
+	fmt.Printf("Downloading %s %s (~%s)\n", info.Title, values.Quality, formatSize(stream.Data.Size))
+	// the stream and the converted output exist at the same time
+	if err = checkDiskSpace(dir, stream.Data.Size*2); err != nil {
+		return err
+	}
+
+	audioTmp, err := os.CreateTemp(dir, "bilibili_audio_*"+ext)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(audioTmp.Name())
+	for _, cdn := range stream.Data.Cdns {
+		if _, err = audioTmp.Seek(0, io.SeekStart); err != nil {
+			break
+		}
+		if err = audioTmp.Truncate(0); err != nil {
+			break
+		}
+		if err = downloadMedia("Audio", cdn, audioTmp); err == nil || isCanceled() {
+			break
+		}
+	}
+	if err != nil {
+		audioTmp.Close()
+		return err
+	}
+	if err = audioTmp.Close(); err != nil {
+		return err
+	}
+
+	tags := songTags(info, values.Album)
+	cover := downloadTagsCover(tags, dir)
+	if err = checkFFmpeg(); err != nil {
+		if "."+format != ext {
+			return fmt.Errorf("audio format %s requires ffmpeg: %w", format, err)
+		}
+		if err = os.Rename(audioTmp.Name(), output); err == nil {
+			// only the m4a streams are tagged without ffmpeg, with the cover downloaded above
+			if isMP4(output) {
+				var data []byte
+				if len(cover) != 0 {
+					data, _ = os.ReadFile(cover)
+				}
+				writeMetadata(output, tags, data)
+			} else {
+				fmt.Println("FFmpeg is not installed, the audio is saved without tags.")
+			}
+		}
+	} else {
+		embedded := ""
+		if audioCodecs[format].cover {
+			embedded = cover
+		}
+		ins.Start()
+		err = convertAudio(audioTmp.Name(), embedded, output, format, tags)
+		ins.Stop()
+	}
+	if err != nil {
+		if len(cover) != 0 {
+			os.Remove(cover)
+		}
+		return err
+	}
+
+	base := strings.TrimSuffix(output, path.Ext(output))
+	if len(cover) != 0 {
+		if err = os.Rename(cover, base+path.Ext(cover)); err != nil {
+			fmt.Printf("Save the cover of %s failed: %v\n", output, err)
+			os.Remove(cover)
+		}
+	}
+	if err = writeLyric(info.AuID, base+".lrc"); err != nil {
+		fmt.Printf("Save the lyrics of %s failed: %v\n", output, err)
+	}
+	recordArchive(int(info.AuID), 0, bilibili.Qn(quality), output)
+	return runHooks(newHookValues(output, values, info.Duration))
+}
+
+// songArchived is archived for the songs, they are keyed by the au id with cid 0 which no video has.
+func songArchived(auID int64, quality bilibili.AudioQuality) bool {
+	location, ok := lookupArchived(int(auID), 0, bilibili.Qn(quality))
+	if ok {
+		fmt.Printf("Skip au%d (%s), it is downloaded to %s already, use --force to download it again.\n", auID, quality, location)
+	}
+	return ok
+}
+
+// songStreamExt is the extension of the stream, .flac for the lossless ones and .m4a for the aac ones.
+func songStreamExt(streamUrl string) string {
+	if u, err := url.Parse(streamUrl); err == nil {
+		if ext := strings.ToLower(path.Ext(u.Path)); ext == ".flac" || ext == ".mp3" {
+			return ext
+		}
+	}
+	return ".m4a"
+}
+
+// writeLyric saves the lrc lyrics of the song into name, nothing is written for the songs without them.
+func writeLyric(auID int64, name string) error {
+	lyric, err := client.AudioLyric(auID)
+	if err != nil || len(strings.TrimSpace(lyric)) == 0 {
+		return err
+	}
+	tmp, err := tempOutput(name)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	if err = os.WriteFile(tmp, []byte(lyric), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("%s is saved.\n", name)
+	return os.Rename(tmp, name)
+}
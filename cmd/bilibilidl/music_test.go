@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/misssonder/bilibili/internal/filename"
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSongStreamExt(t *testing.T) {
+	assert.Equal(t, ".m4a", songStreamExt("https://upos-sz-mirrorkodo.bilivideo.com/ugaxcode/m230123a1234-1-30280.m4a?deadline=1"))
+	assert.Equal(t, ".flac", songStreamExt("https://upos-sz-mirrorkodo.bilivideo.com/ugaxcode/m230123a1234-1-30251.flac?deadline=1"))
+	assert.Equal(t, ".m4a", songStreamExt("https://upos-sz-mirrorkodo.bilivideo.com/ugaxcode/m230123a1234-1-30280.m4s"))
+}
+
+func TestSongValues(t *testing.T) {
+	info := &SongInfo{
+		AuID:        1234,
+		Title:       "song",
+		Author:      "singer",
+		Uploader:    "uploader",
+		Intro:       "intro",
+		PublishTime: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC).Format(time.RFC3339),
+	}
+	name, err := filename.Expand("{album}/{page} {title} au{auid} {pubdate}", songValues(info, "menu", 3))
+	assert.NoError(t, err)
+	assert.Equal(t, "menu/3 song au1234 2023-01-02", name)
+
+	tags := songTags(info, "menu")
+	assert.Equal(t, "singer", tags.Artist)
+	assert.Equal(t, "menu", tags.Album)
+	assert.Equal(t, "2023-01-02", tags.Date)
+	assert.Equal(t, "intro\n\nhttps://www.bilibili.com/audio/au1234", tags.Comment)
+}
+
+func TestSongArchived(t *testing.T) {
+	a, err := loadArchive(path.Join(t.TempDir(), "archive.txt"))
+	assert.NoError(t, err)
+	downloadArchive = a
+	defer func() { downloadArchive = nil }()
+
+	assert.False(t, songArchived(1234, bilibili.AudioQuality320K))
+	recordArchive(1234, 0, bilibili.Qn(bilibili.AudioQuality320K), "menu/1 song.m4a")
+	assert.True(t, songArchived(1234, bilibili.AudioQuality320K))
+	assert.False(t, songArchived(1234, bilibili.AudioQualityFLAC))
+}
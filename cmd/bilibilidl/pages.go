@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/misssonder/bilibili/internal/filename"
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/misssonder/bilibili/pkg/mp4"
+)
+
+var mergePages bool
+
+// pageStreams are the streams selected for every page.
+type pageStreams struct {
+	format       bilibili.Fnval
+	videoQuality bilibili.Qn
+	videoCodec   int
+	audioQuality bilibili.Qn
+}
+
+// downloadPages downloads every page of the video and joins them into one file with a chapter per page.
+func downloadPages(info *VideoInfo) error {
+	if err := checkFFmpeg(); err != nil {
+		return err
+	}
+	format, err := selectFormat()
+	if err != nil {
+		return err
+	}
+	qn, fnval := playUrlParams(format)
+	caches := make([]*playUrlCache, 0, len(info.Pages))
+	responses := make([]*bilibili.PlayUrlResp, 0, len(info.Pages))
+	for _, page := range info.Pages {
+		cache := newPlayUrlCache(info.BvID, page.CID, qn, fnval, nil)
+		playUrlResp, err := cache.get(false)
+		if err != nil {
+			return err
+		}
+		caches = append(caches, cache)
+		responses = append(responses, playUrlResp)
+	}
+
+	values := videoValues(info, info.Pages[0])
+	values.Part, values.Page, values.CID = info.Title, 0, 0
+	tags := videoTags(info, info.Pages[0])
+	tags.Title, tags.Album = info.Title, ""
+
+	streams, err := selectPageStreams(info.BvID, format, responses[0], &values)
+	if err != nil {
+		return err
+	}
+
+	if location, ok := pagesArchived(info, streams.videoQuality); ok {
+		fmt.Printf("Skip %d pages of av%d (%s), they are downloaded to %s already, use --force to download them again.\n", len(info.Pages), info.AID, streams.videoQuality, location)
+		return nil
+	}
+	output, err := outputPath(values, ".mp4")
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, playUrlResp := range responses {
+		if format == bilibili.FnvalMP4 {
+			size += segmentsSize(playUrlResp)
+		} else {
+			size += streamSize(playUrlResp, streams.videoQuality) + streamSize(playUrlResp, streams.audioQuality)
+		}
+	}
+	fmt.Printf("Downloading %d pages of %s (~%s)\n", len(info.Pages), info.Title, formatSize(size))
+	// the pages and the joined output exist at the same time
+	if err = checkDiskSpace(path.Dir(output), size*2); err != nil {
+		return err
+	}
+
+	parts := make([]string, 0, len(info.Pages))
+	defer func() {
+		for _, part := range parts {
+			os.Remove(part)
+		}
+	}()
+	titles := make([]string, 0, len(info.Pages))
+	durations := make([]time.Duration, 0, len(info.Pages))
+	var total time.Duration
+	for i, page := range info.Pages {
+		if isCanceled() {
+			return ctx.Err()
+		}
+		part, err := tempOutput(output)
+		if err != nil {
+			return err
+		}
+		parts = append(parts, part)
+		fmt.Printf("Downloading page %d/%d %s\n", i+1, len(info.Pages), page.Part)
+		expected := time.Duration(responses[i].Data.Timelength) * time.Millisecond
+		if err = downloadPage(caches[i], part, streams, expected); err != nil {
+			return err
+		}
+		duration := page.Duration
+		if partInfo, err := mp4.InspectFile(part); err == nil && partInfo.Duration > 0 {
+			duration = partInfo.Duration
+		}
+		titles = append(titles, page.Part)
+		durations = append(durations, duration)
+		total += duration
+	}
+
+	tags.Chapters = pageChapters(titles, durations)
+	if err = concat(parts, output, tags.Chapters); err != nil {
+		return err
+	}
+	embedMetadata(output, tags)
+	if err = verifyMedia(output, total); err != nil {
+		return fmt.Errorf("%s fails the verification: %w", output, err)
+	}
+	for _, page := range info.Pages {
+		recordArchive(info.AID, page.CID, streams.videoQuality, output)
+	}
+	return runHooks(newHookValues(output, values, total))
+}
+
+// playUrlParams are the quality and the format the play urls of the pages are requested with.
+func playUrlParams(format bilibili.Fnval) (bilibili.Qn, bilibili.Fnval) {
+	if format == bilibili.FnvalMP4 {
+		return bilibili.Qn4k, bilibili.FnvalMP4
+	}
+	return 0, bilibili.FnvalDashAll
+}
+
+// selectPageStreams asks for the qualities by the play url of the first page, they are used for every page.
+func selectPageStreams(bvID string, format bilibili.Fnval, first *bilibili.PlayUrlResp, values *filename.Values) (pageStreams, error) {
+	streams := pageStreams{format: format}
+	if format == bilibili.FnvalMP4 {
+		streams.videoQuality = bilibili.Qn(first.Data.Quality)
+		values.Codec = "avc"
+		values.Quality = first.QnDescription(streams.videoQuality)
+		return streams, nil
+	}
+	if len(first.Data.Dash.Video) == 0 || len(first.AudioStreams()) == 0 {
+		return streams, fmt.Errorf("no dash stream of %s", bvID)
+	}
+	videoQualities := make([]bilibili.Qn, 0, len(first.Data.Dash.Video))
+	for _, video := range first.Data.Dash.Video {
+		videoQualities = append(videoQualities, bilibili.Qn(video.ID))
+	}
+	var err error
+	if streams.videoQuality, err = selectMediaQuality("Please select video quality", videoQualities, sizeLabel(first)); err != nil {
+		return streams, err
+	}
+	audioQualities := make([]bilibili.Qn, 0)
+	for _, audio := range first.AudioStreams() {
+		audioQualities = append(audioQualities, bilibili.Qn(audio.ID))
+	}
+	if streams.audioQuality, err = selectMediaQuality("Please select audio quality", audioQualities, sizeLabel(first)); err != nil {
+		return streams, err
+	}
+	video := chooseDashVideo(first, streams.videoQuality, 0)
+	streams.videoCodec = video.Codecid
+	values.Codec = video.CodecName()
+	values.AudioQuality = first.QnDescription(streams.audioQuality)
+	values.Quality = first.QnDescription(streams.videoQuality)
+	return streams, nil
+}
+
+// pagesArchived reports whether every page is in the download archive and returns the joined output they are downloaded to.
+func pagesArchived(info *VideoInfo, qn bilibili.Qn) (string, bool) {
+	var location string
+	for _, page := range info.Pages {
+		pageLocation, ok := lookupArchived(info.AID, page.CID, qn)
+		if !ok {
+			return "", false
+		}
+		if len(location) == 0 {
+			location = pageLocation
+		}
+	}
+	return location, true
+}
+
+// downloadPage downloads the streams of a page into part.
+func downloadPage(cache *playUrlCache, part string, streams pageStreams, expected time.Duration) error {
+	if streams.format == bilibili.FnvalMP4 {
+		sources, err := cache.segmentSources()
+		if err != nil {
+			return err
+		}
+		if err = downloadSegments(sources, part, expected); err != nil {
+			return err
+		}
+		// the segments are kept when they can not be joined, but a page has to be one file
+		if stat, err := os.Stat(part); err != nil || stat.Size() == 0 {
+			return fmt.Errorf("the segments of %s can not be joined", part)
+		}
+		return nil
+	}
+
+	dir := path.Dir(part)
+	videoTmp, err := os.CreateTemp(dir, "bilibili_video_*.m4s")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(videoTmp.Name())
+	defer videoTmp.Close()
+	audioTmp, err := os.CreateTemp(dir, "bilibili_audio_*.m4s")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(audioTmp.Name())
+	defer audioTmp.Close()
+	if err = downloadVerifiedMedia("Video", cache.dashVideoSource(streams.videoQuality, streams.videoCodec), videoTmp, expected); err != nil {
+		return err
+	}
+	if err = downloadVerifiedMedia("Audio", cache.dashAudioSource(streams.audioQuality), audioTmp, expected); err != nil {
+		return err
+	}
+	_, err = merge(videoTmp.Name(), audioTmp.Name(), part, mediaTags{}, "")
+	return err
+}
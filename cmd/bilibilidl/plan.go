@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/pflag"
+)
+
+var dryRun bool
+
+const (
+	planActionDownload = "download"
+	planActionAdd      = "add"
+	planActionSkip     = "skip"
+)
+
+// PlanItem is what a batch operation would do with a page.
+type PlanItem struct {
+	Action       string
+	Reason       string `json:",omitempty" yaml:",omitempty" xml:",omitempty"`
+	BvID         string
+	CID          int64
+	Title        string
+	Part         string
+	VideoQuality string `json:",omitempty" yaml:",omitempty" xml:",omitempty"`
+	AudioQuality string `json:",omitempty" yaml:",omitempty" xml:",omitempty"`
+	Codec        string `json:",omitempty" yaml:",omitempty" xml:",omitempty"`
+	Output       string `json:",omitempty" yaml:",omitempty" xml:",omitempty"`
+	Size         int64
+
+	video *UpVideoInfo
+	cache *playUrlCache
+	// quality is the one the video is recorded with in the download archive
+	quality bilibili.Qn
+}
+
+type Plan struct {
+	Items []PlanItem
+	Size  int64
+}
+
+func addDryRunFlag(flagSet *pflag.FlagSet) {
+	flagSet.BoolVar(&dryRun, "dry-run", false, "Print the plan without writing files or downloading")
+}
+
+func (plan *Plan) add(item PlanItem) {
+	if item.Action != planActionSkip {
+		plan.Size += item.Size
+	}
+	plan.Items = append(plan.Items, item)
+}
+
+// required is the disk space the download needs: the outputs of all items, and the streams of the largest one
+// which exist alongside its merged output until they are removed.
+func (plan *Plan) required() int64 {
+	var largest int64
+	for _, item := range plan.pending() {
+		if item.Size > largest {
+			largest = item.Size
+		}
+	}
+	return plan.Size + largest
+}
+
+// pending are the items to download.
+func (plan *Plan) pending() []PlanItem {
+	items := make([]PlanItem, 0, len(plan.Items))
+	for _, item := range plan.Items {
+		if item.Action == planActionDownload {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func writePlanOutput(w io.Writer, plan *Plan) {
+	table := tablewriter.NewWriter(w)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{
+		"action",
+		"bvid",
+		"cid",
+		"part",
+		"quality",
+		"size",
+		"output/reason",
+	})
+	var actions int
+	for _, item := range plan.Items {
+		detail := item.Output
+		if item.Action == planActionSkip {
+			detail = item.Reason
+		} else {
+			actions++
+		}
+		quality := item.VideoQuality
+		if len(item.AudioQuality) != 0 {
+			quality += "," + item.AudioQuality
+		}
+		size := ""
+		if item.Size > 0 {
+			size = "~" + formatSize(item.Size)
+		}
+		table.Append([]string{
+			item.Action,
+			item.BvID,
+			strconv.FormatInt(item.CID, 10),
+			item.Part,
+			quality,
+			size,
+			detail,
+		})
+	}
+	table.Render()
+	fmt.Fprintf(w, "%d of %d pages, ~%s in total.\n", actions, len(plan.Items), formatSize(plan.Size))
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanUPerVideos(t *testing.T) {
+	videos := []*UpVideoInfo{
+		{BvID: "BV1", CID: 1, Title: "a", Part: "p1", Location: "/videos/a/p1.mp4"},
+		{BvID: "BV1", CID: 2, Title: "a", Part: "p2", Location: "/videos/a/p2.mp4"},
+	}
+	plan, err := planUPerVideos(videos)
+	assert.NoError(t, err)
+	assert.Len(t, plan.Items, 2)
+	assert.Empty(t, plan.pending())
+	assert.Equal(t, planActionSkip, plan.Items[0].Action)
+	assert.Equal(t, "downloaded to /videos/a/p1.mp4", plan.Items[0].Reason)
+
+	var buf bytes.Buffer
+	writePlanOutput(&buf, plan)
+	assert.Contains(t, buf.String(), "0 of 2 pages")
+}
+
+func TestPlanAdd(t *testing.T) {
+	plan := &Plan{}
+	plan.add(PlanItem{Action: planActionDownload, Size: 10})
+	plan.add(PlanItem{Action: planActionSkip, Size: 5})
+	plan.add(PlanItem{Action: planActionDownload, Size: 20})
+	assert.Equal(t, int64(30), plan.Size)
+	assert.Len(t, plan.pending(), 2)
+	// the streams of the largest item exist alongside the outputs
+	assert.Equal(t, int64(50), plan.required())
+}
+
+func TestArchiveQuality(t *testing.T) {
+	playUrlResp := &bilibili.PlayUrlResp{}
+	playUrlResp.Data.Quality = int(bilibili.Qn720P)
+	// the segments of the videos without dash are recorded with the resolved quality like download
+	assert.Equal(t, bilibili.Qn720P, archiveQuality(&UpVideoInfo{}, playUrlResp))
+	assert.Equal(t, bilibili.Qn1080P, archiveQuality(&UpVideoInfo{VideoQuality: bilibili.Qn1080P}, playUrlResp))
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/misssonder/bilibili/pkg/progress"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	progressModeBar  = "bar"
+	progressModeJSON = "json"
+)
+
+var (
+	progressMode string
+	progressFd   int
+	// progressListener receives the download events in json mode, it is nil when progress bars are rendered.
+	progressListener progress.Listener
+)
+
+func initProgress() error {
+	switch progressMode {
+	case progressModeBar:
+		return nil
+	case progressModeJSON:
+	default:
+		return fmt.Errorf("invalid progress mode: %s", progressMode)
+	}
+	var file *os.File
+	switch progressFd {
+	case 1:
+		file = os.Stdout
+	case 2:
+		file = os.Stderr
+		// logrus writes to stderr as well, its lines would break the json stream
+		logrus.SetOutput(os.Stdout)
+	default:
+		file = os.NewFile(uintptr(progressFd), "progress")
+		if file == nil {
+			return fmt.Errorf("invalid progress file descriptor: %d", progressFd)
+		}
+	}
+	progressListener = progress.NewJSONListener(file)
+	return nil
+}
+
+func emit(event progress.Event) {
+	if progressListener == nil {
+		return
+	}
+	event.Time = time.Now()
+	progressListener.OnEvent(event)
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitProgressMovesLogrus(t *testing.T) {
+	defer func() {
+		progressMode, progressFd, progressListener = progressModeBar, 2, nil
+		logrus.SetOutput(os.Stderr)
+	}()
+	progressMode, progressFd = progressModeJSON, 2
+	assert.NoError(t, initProgress())
+	assert.NotNil(t, progressListener)
+	assert.Equal(t, os.Stdout, logrus.StandardLogger().Out)
+}
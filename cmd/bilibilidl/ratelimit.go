@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/misssonder/bilibili/pkg/ratelimit"
+)
+
+var (
+	// globalLimiter is shared by all the downloads of the process, it is unlimited unless --limit-rate is set.
+	// With --limit-rate-file it follows the rate in the file, which is reread on SIGUSR1.
+	globalLimiter   *ratelimit.Limiter
+	perDownloadRate int64
+)
+
+func initRateLimit() error {
+	rate, err := ratelimit.ParseRate(limitRate)
+	if err != nil {
+		return err
+	}
+	globalLimiter = ratelimit.NewLimiter(rate)
+	if len(limitRateFile) != 0 {
+		if err = reloadRateFile(globalLimiter, limitRateFile); err != nil {
+			return err
+		}
+		watchRateFile(ctx, globalLimiter, limitRateFile)
+	}
+	perDownloadRate, err = ratelimit.ParseRate(limitRatePerDownload)
+	return err
+}
+
+// reloadRateFile sets the rate of limiter to the one written in name, like 2M, an empty file is unlimited.
+func reloadRateFile(limiter *ratelimit.Limiter, name string) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	rate, err := ratelimit.ParseRate(string(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	limiter.SetRate(rate)
+	return nil
+}
+
+func rateString(rate int64) string {
+	if rate <= 0 {
+		return "unlimited"
+	}
+	return formatSize(rate) + "/s"
+}
+
+func newDownloadLimiter() *ratelimit.Limiter {
+	if perDownloadRate <= 0 {
+		return nil
+	}
+	return ratelimit.NewLimiter(perDownloadRate)
+}
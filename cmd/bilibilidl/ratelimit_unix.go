@@ -0,0 +1,35 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/misssonder/bilibili/pkg/ratelimit"
+)
+
+// watchRateFile rereads the rate file into limiter on every SIGUSR1 until ctx is canceled,
+// so the rate of a long running watch or recording can be changed without restarting it.
+func watchRateFile(ctx context.Context, limiter *ratelimit.Limiter, name string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+			}
+			if err := reloadRateFile(limiter, name); err != nil {
+				fmt.Printf("Reload the download rate failed, %s is kept: %v\n", rateString(limiter.Rate()), err)
+				continue
+			}
+			fmt.Printf("The download rate is %s.\n", rateString(limiter.Rate()))
+		}
+	}()
+}
@@ -0,0 +1,36 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/misssonder/bilibili/pkg/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchRateFile(t *testing.T) {
+	name := path.Join(t.TempDir(), "rate")
+	assert.NoError(t, os.WriteFile(name, []byte("2M\n"), 0644))
+	limiter := ratelimit.NewLimiter(0)
+	assert.NoError(t, reloadRateFile(limiter, name))
+	assert.Equal(t, int64(2<<20), limiter.Rate())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watchRateFile(ctx, limiter, name)
+
+	assert.NoError(t, os.WriteFile(name, []byte("500K"), 0644))
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+	assert.Eventually(t, func() bool { return limiter.Rate() == 500<<10 }, 5*time.Second, 10*time.Millisecond)
+
+	// an invalid rate keeps the current one
+	assert.NoError(t, os.WriteFile(name, []byte("fast"), 0644))
+	assert.Error(t, reloadRateFile(limiter, name))
+	assert.Equal(t, int64(500<<10), limiter.Rate())
+}
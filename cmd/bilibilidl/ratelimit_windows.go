@@ -0,0 +1,12 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+
+	"github.com/misssonder/bilibili/pkg/ratelimit"
+)
+
+// watchRateFile does nothing as there is no SIGUSR1 on windows, the rate file is only read at the start.
+func watchRateFile(ctx context.Context, limiter *ratelimit.Limiter, name string) {}
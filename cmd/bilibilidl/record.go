@@ -0,0 +1,521 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/misssonder/bilibili/internal/filename"
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	biliErrors "github.com/misssonder/bilibili/pkg/errors"
+	"github.com/misssonder/bilibili/pkg/flv"
+	"github.com/misssonder/bilibili/pkg/hls"
+	"github.com/misssonder/bilibili/pkg/progress"
+	"github.com/misssonder/bilibili/pkg/ratelimit"
+)
+
+const (
+	// liveIdleTimeout is how long the stream may send nothing before it is reconnected.
+	liveIdleTimeout = 30 * time.Second
+	// flvReconnectGap is put between the last tag and the first one of a new connection.
+	flvReconnectGap = 40
+)
+
+var errStreamEnded = errors.New("the stream ends")
+
+// liveRecorder records a room into files, a new file is started when it reaches the split size or duration.
+// The flv timestamps run on over the reconnections and every file starts from 0.
+type liveRecorder struct {
+	ctx           context.Context
+	room          *liveRoom
+	qn            int
+	quality       string
+	ext           string
+	splitSize     int64
+	splitDuration time.Duration
+
+	file     *os.File
+	name     string
+	size     int64
+	duration time.Duration
+	parts    int
+	files    []string
+
+	flvHeader   flv.Header
+	flvWriter   *flv.Writer
+	metadata    *flv.Tag
+	videoConfig *flv.Tag
+	audioConfig *flv.Tag
+	// connected is set by the first audio or video tag of a connection, which sets delta
+	connected bool
+	delta     int64
+	last      int64
+	fileStart int64
+
+	// chat is nil unless --chat is set
+	chat *chatRecorder
+
+	initUri      string
+	initSegment  []byte
+	lastSequence int64
+}
+
+func newLiveRecorder(ctx context.Context, room *liveRoom) (*liveRecorder, error) {
+	splitSize, err := ratelimit.ParseRate(liveSplitSize)
+	if err != nil {
+		return nil, err
+	}
+	return &liveRecorder{
+		ctx:           ctx,
+		room:          room,
+		qn:            liveQuality,
+		splitSize:     splitSize,
+		splitDuration: liveSplitDuration,
+		last:          -1,
+		lastSequence:  -1,
+	}, nil
+}
+
+// record reconnects the stream until the live ends, ctx is canceled or it fails liveRetries times in a row.
+func (r *liveRecorder) record() error {
+	defer r.closePart()
+	failures := 0
+	for {
+		written, err := r.recordStream()
+		if r.ctx.Err() != nil {
+			return nil
+		}
+		if written > 0 {
+			failures = 0
+		}
+		if roomInit, statusErr := client.RoomInit(r.room.ID); statusErr == nil && roomInit.Data.LiveStatus != bilibili.LiveStatusLive {
+			fmt.Printf("The live of room %d ends.\n", r.room.ID)
+			return nil
+		}
+		failures++
+		if failures > liveRetries {
+			return fmt.Errorf("record room %d: %w", r.room.ID, err)
+		}
+		fmt.Printf("The stream of room %d is interrupted, reconnecting in %s (%d/%d): %v\n", r.room.ID, liveRetryInterval, failures, liveRetries, err)
+		emit(progress.Event{Type: progress.EventRetry, Title: r.room.Title, Path: r.name, Attempt: failures, Error: fmt.Sprint(err)})
+		if !sleepContext(r.ctx, liveRetryInterval) {
+			return nil
+		}
+	}
+}
+
+// recordStream records a connection of the stream, it returns how many bytes are written.
+func (r *liveRecorder) recordStream() (int64, error) {
+	if r.qn == 0 {
+		// the highest quality is known from the accepted ones
+		playInfo, err := client.RoomPlayInfo(r.room.ID, bilibili.LiveQnOriginal)
+		if err != nil {
+			return 0, err
+		}
+		r.qn = bilibili.LiveQnOriginal
+		if qns := playInfo.AcceptQns(); len(qns) != 0 {
+			r.qn = qns[0]
+		}
+	}
+	playInfo, err := client.RoomPlayInfo(r.room.ID, r.qn)
+	if err != nil {
+		return 0, err
+	}
+	if playInfo.Data.LiveStatus != bilibili.LiveStatusLive {
+		return 0, errStreamEnded
+	}
+	stream, ok := chooseLiveStream(playInfo.Streams(), liveProtocol, liveCodec)
+	if !ok {
+		return 0, fmt.Errorf("no %s stream of room %d", liveProtocol, r.room.ID)
+	}
+	quality := playInfo.QnDescription(stream.Qn)
+	if quality != r.quality {
+		if stream.Qn != r.qn {
+			fmt.Printf("Room %d is recorded in %s, %s is not available.\n", r.room.ID, quality, playInfo.QnDescription(r.qn))
+		}
+		r.quality = quality
+	}
+	ext := liveStreamExt(stream)
+	if r.ext != ext && r.file != nil {
+		r.closePart()
+	}
+	r.ext = ext
+
+	var lastErr error
+	for _, streamUrl := range stream.Urls {
+		var written int64
+		if stream.Protocol == bilibili.LiveProtocolFLV {
+			written, err = r.recordFLV(streamUrl)
+		} else {
+			written, err = r.recordHLS(streamUrl)
+		}
+		// the mirrors are tried when one fails at once
+		if written > 0 || r.ctx.Err() != nil {
+			return written, err
+		}
+		lastErr = err
+	}
+	return 0, lastErr
+}
+
+// chooseLiveStream chooses the stream of the protocol in the codec, or in another codec when it is unavailable.
+// The ts format is preferred over fmp4 for hls.
+func chooseLiveStream(streams []bilibili.LiveStream, protocol, codec string) (bilibili.LiveStream, bool) {
+	protocolName := bilibili.LiveProtocolFLV
+	if protocol == liveProtocolHLS {
+		protocolName = bilibili.LiveProtocolHLS
+	}
+	best, bestScore := bilibili.LiveStream{}, -1
+	for _, stream := range streams {
+		if stream.Protocol != protocolName || len(stream.Urls) == 0 {
+			continue
+		}
+		score := 0
+		if stream.Codec == codec {
+			score += 2
+		}
+		if stream.Format != bilibili.LiveFormatFMP4 {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = stream, score
+		}
+	}
+	return best, bestScore >= 0
+}
+
+func liveStreamExt(stream bilibili.LiveStream) string {
+	switch stream.Format {
+	case bilibili.LiveFormatTS:
+		return ".ts"
+	case bilibili.LiveFormatFMP4:
+		return ".mp4"
+	default:
+		return ".flv"
+	}
+}
+
+func liveRequest(ctx context.Context, streamUrl string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, streamUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Referer", "https://live.bilibili.com")
+	request.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/117.0.0.0 Safari/537.36")
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, biliErrors.ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// idleReader pushes the timer back on every read, the timer cancels the request when the stream stalls.
+type idleReader struct {
+	r     io.Reader
+	timer *time.Timer
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.timer.Reset(liveIdleTimeout)
+	return n, err
+}
+
+func (r *liveRecorder) recordFLV(streamUrl string) (int64, error) {
+	requestCtx, cancel := context.WithCancel(r.ctx)
+	defer cancel()
+	resp, err := liveRequest(requestCtx, streamUrl)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	timer := time.AfterFunc(liveIdleTimeout, cancel)
+	defer timer.Stop()
+	reader, header, err := flv.NewReader(bufio.NewReader(&idleReader{r: resp.Body, timer: timer}))
+	if err != nil {
+		return 0, err
+	}
+	r.flvHeader = header
+	r.connected = false
+	var written int64
+	for {
+		tag, err := reader.ReadTag()
+		if err == io.EOF {
+			err = errStreamEnded
+		}
+		if err != nil {
+			return written, err
+		}
+		n, err := r.writeFLVTag(tag)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
+// writeFLVTag writes the tag into the current file, a file starts from a keyframe with the metadata and
+// the sequence headers, so each of them plays on its own.
+func (r *liveRecorder) writeFLVTag(tag flv.Tag) (int64, error) {
+	switch {
+	case tag.Type == flv.TagScript:
+		r.metadata = &tag
+		return 0, nil
+	case tag.IsSequenceHeader():
+		if tag.Type == flv.TagVideo {
+			r.videoConfig = &tag
+		} else {
+			r.audioConfig = &tag
+		}
+		// a new connection may change the codec parameters
+		if r.file == nil {
+			return 0, nil
+		}
+		tag.Timestamp = uint32(r.fileTime(r.last))
+		return r.writeTag(tag)
+	case tag.Type != flv.TagAudio && tag.Type != flv.TagVideo:
+		return 0, nil
+	}
+
+	if !r.connected {
+		r.connected = true
+		r.delta = -int64(tag.Timestamp)
+		if r.last >= 0 {
+			r.delta += r.last + flvReconnectGap
+		}
+	}
+	t := int64(tag.Timestamp) + r.delta
+	if r.file == nil || r.exceeded() {
+		if tag.IsKeyframe() || !r.flvHeader.Video {
+			if err := r.openFLVPart(t); err != nil {
+				return 0, err
+			}
+		} else if r.file == nil {
+			return 0, nil
+		}
+	}
+	if t > r.last {
+		r.last = t
+	}
+	tag.Timestamp = uint32(r.fileTime(t))
+	if duration := time.Duration(tag.Timestamp) * time.Millisecond; duration > r.duration {
+		r.duration = duration
+	}
+	return r.writeTag(tag)
+}
+
+func (r *liveRecorder) fileTime(t int64) int64 {
+	if t < r.fileStart {
+		return 0
+	}
+	return t - r.fileStart
+}
+
+func (r *liveRecorder) openFLVPart(t int64) error {
+	if err := r.openPart(); err != nil {
+		return err
+	}
+	writer, err := flv.NewWriter(r.file, r.flvHeader)
+	if err != nil {
+		return err
+	}
+	r.flvWriter = writer
+	r.fileStart = t
+	for _, tag := range []*flv.Tag{r.metadata, r.videoConfig, r.audioConfig} {
+		if tag == nil {
+			continue
+		}
+		header := *tag
+		header.Timestamp = 0
+		if _, err = r.writeTag(header); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *liveRecorder) writeTag(tag flv.Tag) (int64, error) {
+	if err := r.flvWriter.WriteTag(tag); err != nil {
+		return 0, err
+	}
+	// the tag header and the size following the tag
+	n := int64(11 + len(tag.Data) + 4)
+	r.size += n
+	return n, nil
+}
+
+func (r *liveRecorder) recordHLS(playlistUrl string) (int64, error) {
+	var written int64
+	updated := time.Now()
+	for {
+		body, err := r.get(playlistUrl)
+		if err != nil {
+			return written, err
+		}
+		base, _ := url.Parse(playlistUrl)
+		playlist, err := hls.Parse(bytes.NewReader(body), base)
+		if err != nil {
+			return written, err
+		}
+		if len(playlist.Variants) != 0 {
+			playlistUrl = playlist.Variants[0]
+			continue
+		}
+		if len(playlist.Map) != 0 && playlist.Map != r.initUri {
+			if r.initSegment, err = r.get(playlist.Map); err != nil {
+				return written, err
+			}
+			// the segments after a new initialization section do not fit the former file
+			if len(r.initUri) != 0 {
+				r.closePart()
+			}
+			r.initUri = playlist.Map
+		}
+		// the sequence starts over when the stream restarts
+		if n := len(playlist.Segments); n != 0 && playlist.Segments[n-1].Sequence < r.lastSequence {
+			r.lastSequence = -1
+		}
+		for _, segment := range playlist.Segments {
+			if segment.Sequence <= r.lastSequence {
+				continue
+			}
+			data, err := r.get(segment.URI)
+			if err != nil {
+				return written, err
+			}
+			if err = r.writeSegment(data, segment.Duration); err != nil {
+				return written, err
+			}
+			written += int64(len(data))
+			r.lastSequence = segment.Sequence
+			updated = time.Now()
+		}
+		if playlist.EndList {
+			return written, errStreamEnded
+		}
+		if time.Since(updated) > liveIdleTimeout {
+			return written, fmt.Errorf("no segment is added in %s", liveIdleTimeout)
+		}
+		wait := playlist.TargetDuration / 2
+		if wait <= 0 {
+			wait = time.Second
+		}
+		if !sleepContext(r.ctx, wait) {
+			return written, r.ctx.Err()
+		}
+	}
+}
+
+func (r *liveRecorder) get(resourceUrl string) ([]byte, error) {
+	requestCtx, cancel := context.WithTimeout(r.ctx, liveIdleTimeout)
+	defer cancel()
+	resp, err := liveRequest(requestCtx, resourceUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// writeSegment appends the hls segment, a new file starts with the initialization section of fmp4.
+func (r *liveRecorder) writeSegment(data []byte, duration time.Duration) error {
+	if r.file == nil || r.exceeded() {
+		if err := r.openPart(); err != nil {
+			return err
+		}
+		if len(r.initSegment) != 0 {
+			if _, err := r.file.Write(r.initSegment); err != nil {
+				return err
+			}
+			r.size += int64(len(r.initSegment))
+		}
+	}
+	if _, err := r.file.Write(data); err != nil {
+		return err
+	}
+	r.size += int64(len(data))
+	r.duration += duration
+	return nil
+}
+
+func (r *liveRecorder) exceeded() bool {
+	return r.splitSize > 0 && r.size >= r.splitSize || r.splitDuration > 0 && r.duration >= r.splitDuration
+}
+
+// openPart closes the current file and creates the next one, the files are written in place,
+// so an interrupted recording keeps what it has recorded.
+func (r *liveRecorder) openPart() error {
+	r.closePart()
+	r.parts++
+	// {start} is the start of the broadcast, so all the parts of it share the time
+	start := r.room.LiveStart
+	if start.IsZero() {
+		start = time.Now()
+	}
+	values := filename.Values{
+		Title:    r.room.Title,
+		Uploader: r.room.Uploader,
+		Mid:      int(r.room.Uid),
+		Room:     r.room.ID,
+		Start:    start,
+		Quality:  r.quality,
+		Page:     r.parts,
+	}
+	name, err := expandOutput(liveOutputDir, liveOutputFile, values, r.ext)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	r.file, r.name, r.size, r.duration = file, name, 0, 0
+	r.chat.open(name)
+	fmt.Printf("Recording room %d (%s) to %s\n", r.room.ID, r.quality, name)
+	emit(progress.Event{Type: progress.EventStarted, Title: r.room.Title, Path: name})
+	return nil
+}
+
+func (r *liveRecorder) closePart() {
+	if r.file == nil {
+		return
+	}
+	err := r.file.Close()
+	r.file, r.flvWriter = nil, nil
+	r.chat.close(r.size != 0 && err == nil)
+	if r.size == 0 {
+		os.Remove(r.name)
+		return
+	}
+	if err != nil {
+		fmt.Printf("Close %s failed: %v\n", r.name, err)
+		emit(progress.Event{Type: progress.EventFailed, Title: r.room.Title, Path: r.name, Error: err.Error()})
+		return
+	}
+	r.files = append(r.files, r.name)
+	fmt.Printf("%s is saved (%s, %s).\n", r.name, formatSize(r.size), r.duration.Round(time.Second))
+	emit(progress.Event{Type: progress.EventFinished, Title: r.room.Title, Path: r.name, Bytes: r.size})
+}
+
+// sleepContext sleeps for d, it returns false when ctx is canceled first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
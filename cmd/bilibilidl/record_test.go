@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/misssonder/bilibili/pkg/danmaku"
+	"github.com/misssonder/bilibili/pkg/flv"
+	"github.com/misssonder/bilibili/pkg/livechat"
+	"github.com/stretchr/testify/assert"
+)
+
+func readFLVTags(t *testing.T, name string) []flv.Tag {
+	file, err := os.Open(name)
+	assert.NoError(t, err)
+	defer file.Close()
+	reader, _, err := flv.NewReader(file)
+	assert.NoError(t, err)
+	tags := make([]flv.Tag, 0)
+	for {
+		tag, err := reader.ReadTag()
+		if err == io.EOF {
+			return tags
+		}
+		assert.NoError(t, err)
+		tags = append(tags, tag)
+	}
+}
+
+func TestLiveRecorderFLV(t *testing.T) {
+	liveOutputDir, liveOutputFile = t.TempDir(), "{room} {page}"
+	defer func() { liveOutputDir, liveOutputFile = ".", "{room} {uploader} {start}" }()
+	recorder, err := newLiveRecorder(context.Background(), &liveRoom{ID: 1, Title: "title", Uploader: "uploader"})
+	assert.NoError(t, err)
+	recorder.splitDuration = time.Second
+	recorder.ext = ".flv"
+	recorder.flvHeader = flv.Header{Audio: true, Video: true}
+
+	script := flv.Tag{Type: flv.TagScript, Timestamp: 0, Data: []byte{2}}
+	videoConfig := flv.Tag{Type: flv.TagVideo, Timestamp: 0, Data: []byte{0x17, 0}}
+	audioConfig := flv.Tag{Type: flv.TagAudio, Timestamp: 0, Data: []byte{0xAF, 0}}
+	keyframe := func(ts uint32) flv.Tag { return flv.Tag{Type: flv.TagVideo, Timestamp: ts, Data: []byte{0x17, 1}} }
+	frame := func(ts uint32) flv.Tag { return flv.Tag{Type: flv.TagVideo, Timestamp: ts, Data: []byte{0x27, 1}} }
+	audio := func(ts uint32) flv.Tag { return flv.Tag{Type: flv.TagAudio, Timestamp: ts, Data: []byte{0xAF, 1}} }
+
+	for _, tag := range []flv.Tag{
+		script, videoConfig, audioConfig,
+		// a file starts from a keyframe
+		frame(1000),
+		keyframe(1040), audio(1500), frame(2100),
+		// the file exceeds the split duration
+		keyframe(2140),
+	} {
+		_, err = recorder.writeFLVTag(tag)
+		assert.NoError(t, err)
+	}
+	// a new connection starts its timestamps over
+	recorder.connected = false
+	_, err = recorder.writeFLVTag(keyframe(5))
+	assert.NoError(t, err)
+	recorder.closePart()
+
+	assert.Len(t, recorder.files, 2)
+	timestamps := func(tags []flv.Tag) []uint32 {
+		result := make([]uint32, 0, len(tags))
+		for _, tag := range tags {
+			result = append(result, tag.Timestamp)
+		}
+		return result
+	}
+	first := readFLVTags(t, recorder.files[0])
+	assert.Equal(t, []flv.Tag{script, videoConfig, audioConfig}, first[:3])
+	assert.Equal(t, []uint32{0, 0, 0, 0, 460, 1060}, timestamps(first))
+	second := readFLVTags(t, recorder.files[1])
+	assert.Equal(t, []flv.Tag{script, videoConfig, audioConfig}, second[:3])
+	assert.Equal(t, []uint32{0, 0, 0, 0, 40}, timestamps(second))
+}
+
+func TestLiveRecorderSegments(t *testing.T) {
+	liveOutputDir, liveOutputFile = t.TempDir(), "{room} {page}"
+	defer func() { liveOutputDir, liveOutputFile = ".", "{room} {uploader} {start}" }()
+	recorder, err := newLiveRecorder(context.Background(), &liveRoom{ID: 1})
+	assert.NoError(t, err)
+	recorder.splitSize = 9
+	recorder.ext = ".mp4"
+	recorder.initSegment = []byte("init")
+	for _, segment := range []string{"aaaa", "bbbb", "cccc"} {
+		assert.NoError(t, recorder.writeSegment([]byte(segment), time.Second))
+	}
+	recorder.closePart()
+	assert.Len(t, recorder.files, 2)
+	data, err := os.ReadFile(recorder.files[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "initaaaabbbb", string(data))
+	data, err = os.ReadFile(recorder.files[1])
+	assert.NoError(t, err)
+	assert.Equal(t, "initcccc", string(data))
+}
+
+func TestChooseLiveStream(t *testing.T) {
+	streams := []bilibili.LiveStream{
+		{Protocol: bilibili.LiveProtocolFLV, Format: "flv", Codec: "hevc", Urls: []string{"flv-hevc"}},
+		{Protocol: bilibili.LiveProtocolFLV, Format: "flv", Codec: "avc", Urls: []string{"flv-avc"}},
+		{Protocol: bilibili.LiveProtocolHLS, Format: "fmp4", Codec: "avc", Urls: []string{"fmp4-avc"}},
+		{Protocol: bilibili.LiveProtocolHLS, Format: "ts", Codec: "avc", Urls: []string{"ts-avc"}},
+		{Protocol: bilibili.LiveProtocolHLS, Format: "fmp4", Codec: "hevc", Urls: []string{"fmp4-hevc"}},
+	}
+	stream, ok := chooseLiveStream(streams, liveProtocolFLV, "avc")
+	assert.True(t, ok)
+	assert.Equal(t, "flv-avc", stream.Urls[0])
+	stream, _ = chooseLiveStream(streams, liveProtocolHLS, "avc")
+	assert.Equal(t, "ts-avc", stream.Urls[0])
+	stream, _ = chooseLiveStream(streams, liveProtocolHLS, "hevc")
+	assert.Equal(t, "fmp4-hevc", stream.Urls[0])
+	_, ok = chooseLiveStream(streams[:2], liveProtocolHLS, "avc")
+	assert.False(t, ok)
+}
+
+func TestParseRoomID(t *testing.T) {
+	for _, s := range []string{"21452505", "https://live.bilibili.com/21452505?spm_id_from=333", "live.bilibili.com/h5/21452505"} {
+		id, err := parseRoomID(s)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(21452505), id)
+	}
+	_, err := parseRoomID("BV1xx411c7mD")
+	assert.Error(t, err)
+}
+
+func TestLiveWatcher(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var (
+		mu       sync.Mutex
+		checks   = make(map[int64]int)
+		recorded = make(chan int64, 10)
+	)
+	watcher := &liveWatcher{
+		ctx:      ctx,
+		ids:      []int64{1, 2},
+		interval: 10 * time.Millisecond,
+		isLive: func(id int64) (bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			checks[id]++
+			switch {
+			case id == 1:
+				return true, nil
+			case checks[id] == 1:
+				return false, errors.New("failed")
+			default:
+				return checks[id] >= 3, nil
+			}
+		},
+		record: func(id int64) error {
+			recorded <- id
+			if id == 2 {
+				// room 2 records until it is stopped
+				<-ctx.Done()
+			}
+			return nil
+		},
+	}
+	done := make(chan struct{})
+	go func() {
+		watcher.watch()
+		close(done)
+	}()
+
+	// room 1 is recorded again after its recording ends, room 2 once it goes live
+	counts := make(map[int64]int)
+	for counts[1] < 2 || counts[2] < 1 {
+		select {
+		case id := <-recorded:
+			counts[id]++
+		case <-time.After(5 * time.Second):
+			t.Fatal("the rooms are not recorded")
+		}
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the watcher does not stop")
+	}
+	assert.Equal(t, 1, counts[2])
+	mu.Lock()
+	assert.GreaterOrEqual(t, checks[2], 3)
+	mu.Unlock()
+}
+
+func TestChatRecorder(t *testing.T) {
+	dir := t.TempDir()
+	chat := &chatRecorder{roomID: 1}
+	chat.add(&livechat.Danmaku{Content: "dropped"})
+
+	output := filepath.Join(dir, "room.flv")
+	chat.open(output)
+	chat.add(&livechat.Danmaku{User: livechat.User{Uid: 1, Uname: "alice"}, Content: "hello", Mode: 1, FontSize: 25, Color: 0xffffff})
+	chat.add(&livechat.Gift{User: livechat.User{Uid: 2, Uname: "bob"}, GiftName: "小花花", Num: 1})
+	chat.close(true)
+
+	data, err := os.ReadFile(filepath.Join(dir, "room.chat.jsonl"))
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"cmd":"DANMU_MSG"`)
+	assert.Contains(t, lines[1], `"gift_name":"小花花"`)
+	file, err := os.Open(filepath.Join(dir, "room.danmaku.xml"))
+	assert.NoError(t, err)
+	defer file.Close()
+	list, err := danmaku.ParseXML(file)
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, "hello", list[0].Content)
+
+	// the chat of an empty file is removed
+	output = filepath.Join(dir, "empty.flv")
+	chat.open(output)
+	chat.close(false)
+	_, err = os.Stat(filepath.Join(dir, "empty.chat.jsonl"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLiveRecorderStart(t *testing.T) {
+	liveOutputDir, liveOutputFile = t.TempDir(), "{room} {start} {page}"
+	defer func() { liveOutputDir, liveOutputFile = ".", "{room} {uploader} {start}" }()
+	start := time.Date(2023, 11, 14, 20, 0, 0, 0, time.Local)
+	recorder, err := newLiveRecorder(context.Background(), &liveRoom{ID: 1, LiveStart: start})
+	assert.NoError(t, err)
+	recorder.ext = ".flv"
+	// the parts of a broadcast are named with its start rather than their own
+	assert.NoError(t, recorder.openPart())
+	assert.Equal(t, "1 2023-11-14 20-00-00 1.flv", filepath.Base(recorder.name))
+	assert.NoError(t, recorder.openPart())
+	assert.Equal(t, "1 2023-11-14 20-00-00 2.flv", filepath.Base(recorder.name))
+	recorder.closePart()
+}
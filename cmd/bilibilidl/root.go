@@ -9,7 +9,10 @@ import (
 )
 
 var (
-	verbose bool
+	verbose              bool
+	limitRate            string
+	limitRatePerDownload string
+	limitRateFile        string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -17,6 +20,12 @@ var rootCmd = &cobra.Command{
 	Use:   os.Args[0],
 	Short: "Bilibili Downloader",
 	Long:  ``,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := initProgress(); err != nil {
+			return err
+		}
+		return initRateLimit()
+	},
 }
 
 func init() {
@@ -26,4 +35,9 @@ func init() {
 		}
 	})
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
+	rootCmd.PersistentFlags().StringVar(&progressMode, "progress", progressModeBar, "The progress output (bar/json), json writes newline delimited events")
+	rootCmd.PersistentFlags().IntVar(&progressFd, "progress-fd", 2, "The file descriptor json progress events are written to, the logs move to stdout when it is 2")
+	rootCmd.PersistentFlags().StringVar(&limitRate, "limit-rate", "", "Limit the total download rate shared by all downloads, e.g. 500K or 2M")
+	rootCmd.PersistentFlags().StringVar(&limitRateFile, "limit-rate-file", "", "Read the total download rate from the file, it is reread on SIGUSR1 to change the rate at runtime")
+	rootCmd.PersistentFlags().StringVar(&limitRatePerDownload, "limit-rate-per-download", "", "Limit the download rate of every single download, e.g. 500K or 2M")
 }
@@ -2,10 +2,12 @@ package main
 
 import (
 	"fmt"
+	"github.com/misssonder/bilibili/internal/filename"
 	bilibili "github.com/misssonder/bilibili/pkg/client"
 	"github.com/misssonder/bilibili/pkg/video"
 	"github.com/samber/lo"
 	"github.com/spf13/cobra"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -19,6 +21,9 @@ var searchCmd = &cobra.Command{
 	Short: "search keyword and generate videos.yaml",
 	Args:  cobra.ExactArgs(1),
 	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkOutputFormat(); err != nil {
+			return err
+		}
 		return login()
 	},
 	Run: func(cmd *cobra.Command, args []string) {
@@ -28,6 +33,8 @@ var searchCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(searchCmd)
+	addDryRunFlag(searchCmd.Flags())
+	addFormatFlag(searchCmd.Flags())
 	initAllVideos()
 }
 
@@ -68,14 +75,21 @@ func search(keyword string) error {
 		return err
 	}
 
+	plan := &Plan{}
 	if len(videos) > 0 {
-		updateVideos(videos)
+		updateVideos(videos, plan)
 	}
 
+	if dryRun {
+		return writeOutput(os.Stdout, plan, func(w io.Writer) {
+			writePlanOutput(w, plan)
+		})
+	}
 	return nil
 }
 
-func updateVideos(videos []string) {
+// updateVideos adds the new pages into videos.yaml, in dry-run mode they are added into plan only.
+func updateVideos(videos []string, plan *Plan) {
 	for _, vurl := range videos {
 		bvID, err := video.ExtractBvID(vurl)
 		if err != nil {
@@ -90,6 +104,14 @@ func updateVideos(videos []string) {
 		for _, page := range info.Pages {
 			_, ok := findVideo(info, page)
 			if ok {
+				plan.add(PlanItem{
+					Action: planActionSkip,
+					Reason: "already in videos.yaml",
+					BvID:   info.BvID,
+					CID:    page.CID,
+					Title:  info.Title,
+					Part:   page.Part,
+				})
 				continue
 			}
 
@@ -98,14 +120,32 @@ func updateVideos(videos []string) {
 				AID:         info.AID,
 				Title:       info.Title,
 				Part:        page.Part,
+				Page:        page.Page,
 				Author:      info.Author,
+				Uploader:    info.Uploader,
+				Mid:         info.UploaderMid,
 				Duration:    info.Duration,
 				PublishTime: info.PublishTime,
 				CID:         page.CID,
 			}
-			videoInfo, err = setAV(videoInfo)
+			item := PlanItem{
+				Action: planActionAdd,
+				BvID:   info.BvID,
+				CID:    page.CID,
+				Title:  info.Title,
+				Part:   page.Part,
+			}
+			playUrlResp, err := client.PlayUrl(videoInfo.BvID, videoInfo.CID, 0, bilibili.FnvalDashAll)
 			if err != nil {
 				log.Printf("Set AV failed: %v\n", err)
+			} else {
+				setStreams(videoInfo, playUrlResp)
+				fillPlanItem(&item, videoInfo, playUrlResp)
+			}
+			if dryRun {
+				item.Output, _ = resolveOutput(getUPerVideosListFolderLocation(videoInfo.Author), uperTemplate(videoInfo), videoInfo.values(), ".mp4")
+				plan.add(item)
+				continue
 			}
 			AllVideos[info.Author] = append(AllVideos[info.Author], videoInfo)
 
@@ -129,35 +169,25 @@ func findVideo(v *VideoInfo, p Page) (*UpVideoInfo, bool) {
 	return nil, false
 }
 
-func setAV(v *UpVideoInfo) (*UpVideoInfo, error) {
-	playUrlResp, err := client.PlayUrl(v.BvID, v.CID, 0, bilibili.FnvalDash)
-	if err != nil {
-		return v, err
-	}
-
-	if len(playUrlResp.Data.Dash.Video) > 0 && len(playUrlResp.Data.Dash.Audio) > 0 {
+// setStreams keeps the identities of the best streams, the urls expire and are requested again when downloading.
+func setStreams(v *UpVideoInfo, playUrlResp *bilibili.PlayUrlResp) *UpVideoInfo {
+	audios := playUrlResp.AudioStreams()
+	if len(playUrlResp.Data.Dash.Video) > 0 && len(audios) > 0 {
 		maxVideo := lo.MaxBy(playUrlResp.Data.Dash.Video, func(item, max bilibili.DashVideo) bool {
 			return item.ID > max.ID
 		})
 
 		v.VideoQuality = bilibili.Qn(maxVideo.ID)
-		v.VideoURL = chooseMediaUrl(playUrlResp, v.VideoQuality)
+		v.VideoCodec = maxVideo.Codecid
 
-		maxAudio := lo.MaxBy(playUrlResp.Data.Dash.Audio, func(item, max bilibili.DashAudio) bool {
-			return item.ID > max.ID
+		maxAudio := lo.MaxBy(audios, func(item, max bilibili.DashAudio) bool {
+			return bilibili.Qn(max.ID).Less(bilibili.Qn(item.ID))
 		})
 
 		v.AudioQuality = bilibili.Qn(maxAudio.ID)
-		v.AudioURL = chooseMediaUrl(playUrlResp, v.AudioQuality)
-
-		return v, nil
-	}
-
-	if len(playUrlResp.Data.Durl) > 0 && playUrlResp.Data.Durl[0].URL != "" {
-		v.DownloadURL = playUrlResp.Data.Durl[0].URL
 	}
 
-	return v, nil
+	return v
 }
 
 type UpVideoInfo struct {
@@ -165,22 +195,44 @@ type UpVideoInfo struct {
 	AID          int           `json:"aid"`
 	Title        string        `json:"title"`
 	Part         string        `json:"part"`
+	Page         int           `json:"page"`
 	Author       string        `json:"author"`
+	Uploader     string        `json:"uploader"`
+	Mid          int           `json:"mid"`
 	Duration     time.Duration `json:"duration"`
 	PublishTime  string        `json:"pubdate"`
 	CID          int64         `json:"cid"`
 	VideoQuality bilibili.Qn   `json:"video_quality"`
 	AudioQuality bilibili.Qn   `json:"audio_quality"`
-	VideoURL     string        `json:"video_url"`
-	AudioURL     string        `json:"audio_url"`
-	DownloadURL  string        `json:"download_url"`
+	VideoCodec   int           `json:"video_codec"`
 	Location     string        `json:"location"`
 }
 
+func (v *UpVideoInfo) values() filename.Values {
+	pubdate, _ := time.Parse(time.RFC3339, v.PublishTime)
+	uploader := v.Uploader
+	if len(uploader) == 0 {
+		uploader = v.Author
+	}
+	return filename.Values{
+		Title:        v.Title,
+		Part:         v.Part,
+		Page:         v.Page,
+		BvID:         v.BvID,
+		AID:          v.AID,
+		CID:          v.CID,
+		Uploader:     uploader,
+		Mid:          v.Mid,
+		Pubdate:      pubdate,
+		Quality:      v.VideoQuality.String(),
+		AudioQuality: v.AudioQuality.String(),
+	}
+}
+
 func getUPerVideosListFileLocation(uper string) string {
 	return filepath.Join(getUPerVideosListFolderLocation(uper), "videos.yaml")
 }
 
 func getUPerVideosListFolderLocation(uper string) string {
-	return filepath.Join(getVideoLocation(), uper)
+	return filepath.Join(getVideoLocation(), filename.Sanitize(uper))
 }
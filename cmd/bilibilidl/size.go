@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/misssonder/bilibili/pkg/errors"
+)
+
+// estimateSize is the size of a stream of bandwidth bits per second.
+func estimateSize(bandwidth int, duration time.Duration) int64 {
+	return int64(float64(bandwidth) / 8 * duration.Seconds())
+}
+
+// streamSize estimates the size of the dash stream of qn from its bandwidth.
+func streamSize(playUrlResp *bilibili.PlayUrlResp, qn bilibili.Qn) int64 {
+	duration := time.Duration(playUrlResp.Data.Timelength) * time.Millisecond
+	if qn.IsAudio() {
+		for _, audio := range playUrlResp.AudioStreams() {
+			if audio.ID == int(qn) {
+				return estimateSize(audio.Bandwidth, duration)
+			}
+		}
+		return 0
+	}
+	if len(playUrlResp.Data.Dash.Video) == 0 {
+		return 0
+	}
+	return estimateSize(chooseDashVideo(playUrlResp, qn, 0).Bandwidth, duration)
+}
+
+// segmentsSize is the size of the durl segments.
+func segmentsSize(playUrlResp *bilibili.PlayUrlResp) int64 {
+	var size int64
+	for _, durl := range playUrlResp.Data.Durl {
+		size += int64(durl.Size)
+	}
+	return size
+}
+
+// sizeLabel shows the estimated size next to the quality description.
+func sizeLabel(playUrlResp *bilibili.PlayUrlResp) func(bilibili.Qn) string {
+	return func(qn bilibili.Qn) string {
+		description := playUrlResp.QnDescription(qn)
+		if size := streamSize(playUrlResp, qn); size > 0 {
+			return fmt.Sprintf("%s (~%s)", description, formatSize(size))
+		}
+		return description
+	}
+}
+
+// probeSize requests the first byte of the stream to read its size from Content-Range.
+func probeSize(source mediaSource) (int64, error) {
+	url, err := source(false)
+	if err != nil {
+		return 0, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Add("referer", "https://www.bilibili.com")
+	request.Header.Add("range", "bytes=0-0")
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if resp.ContentLength < 0 {
+			return 0, fmt.Errorf("unknown size of %s", url)
+		}
+		return resp.ContentLength, nil
+	case http.StatusPartialContent:
+		contentRange := resp.Header.Get("Content-Range")
+		total := contentRange[strings.LastIndex(contentRange, "/")+1:]
+		size, err := strconv.ParseInt(total, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid Content-Range %q of %s", contentRange, url)
+		}
+		return size, nil
+	default:
+		return 0, errors.ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+}
+
+// probeSizeOr probes the size of the stream, the estimated size is used if the probe fails.
+func probeSizeOr(source mediaSource, estimated int64) int64 {
+	size, err := probeSize(source)
+	if err != nil {
+		return estimated
+	}
+	return size
+}
+
+// checkDiskSpace refuses to download when the filesystem of dir has not the room of size bytes.
+func checkDiskSpace(dir string, size int64) error {
+	free, err := freeSpace(dir)
+	if err != nil {
+		// the free space is unknown, let the download try
+		return nil
+	}
+	if size > free {
+		return fmt.Errorf("not enough disk space in %s: %s is needed but only %s is free", dir, formatSize(size), formatSize(free))
+	}
+	return nil
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "512 B", formatSize(512))
+	assert.Equal(t, "1.5 KiB", formatSize(1536))
+	assert.Equal(t, "2.0 GiB", formatSize(2<<30))
+}
+
+func TestEstimateSize(t *testing.T) {
+	assert.Equal(t, int64(1_000_000), estimateSize(800_000, 10*time.Second))
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, checkDiskSpace(dir, 1))
+	assert.Error(t, checkDiskSpace(dir, 1<<62))
+}
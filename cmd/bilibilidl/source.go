@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/misssonder/bilibili/pkg/errors"
+)
+
+// mediaSource returns the url of a stream, refresh asks for a fresh playurl since the urls expire after a while.
+type mediaSource func(refresh bool) (string, error)
+
+// playUrlCache shares one playurl response between the streams of a page.
+type playUrlCache struct {
+	mu    sync.Mutex
+	bvID  string
+	cid   int64
+	qn    bilibili.Qn
+	fnval bilibili.Fnval
+	resp  *bilibili.PlayUrlResp
+}
+
+func newPlayUrlCache(bvID string, cid int64, qn bilibili.Qn, fnval bilibili.Fnval, resp *bilibili.PlayUrlResp) *playUrlCache {
+	return &playUrlCache{bvID: bvID, cid: cid, qn: qn, fnval: fnval, resp: resp}
+}
+
+func (cache *playUrlCache) get(refresh bool) (*bilibili.PlayUrlResp, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.resp != nil && !refresh {
+		return cache.resp, nil
+	}
+	resp, err := client.PlayUrl(cache.bvID, cache.cid, cache.qn, cache.fnval)
+	if err != nil {
+		return nil, err
+	}
+	cache.resp = resp
+	return resp, nil
+}
+
+// forget drops the response, the next get asks for a fresh one.
+func (cache *playUrlCache) forget() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.resp = nil
+}
+
+// dashVideoSource is the dash video stream of qn, a zero codecid accepts any codec.
+func (cache *playUrlCache) dashVideoSource(qn bilibili.Qn, codecid int) mediaSource {
+	return func(refresh bool) (string, error) {
+		resp, err := cache.get(refresh)
+		if err != nil {
+			return "", err
+		}
+		if len(resp.Data.Dash.Video) == 0 {
+			return "", fmt.Errorf("no dash video stream of %s", cache.bvID)
+		}
+		return chooseDashVideo(resp, qn, codecid).BaseURL, nil
+	}
+}
+
+func (cache *playUrlCache) dashAudioSource(qn bilibili.Qn) mediaSource {
+	return func(refresh bool) (string, error) {
+		resp, err := cache.get(refresh)
+		if err != nil {
+			return "", err
+		}
+		if len(resp.AudioStreams()) == 0 {
+			return "", fmt.Errorf("no dash audio stream of %s", cache.bvID)
+		}
+		return chooseMediaUrl(resp, qn), nil
+	}
+}
+
+// segmentSources are the durl segments in playback order.
+func (cache *playUrlCache) segmentSources() ([]mediaSource, error) {
+	resp, err := cache.get(false)
+	if err != nil {
+		return nil, err
+	}
+	sources := make([]mediaSource, 0, len(resp.Data.Durl))
+	for i := range resp.SegmentURLs() {
+		i := i
+		sources = append(sources, func(refresh bool) (string, error) {
+			resp, err := cache.get(refresh)
+			if err != nil {
+				return "", err
+			}
+			urls := resp.SegmentURLs()
+			if i >= len(urls) {
+				return "", fmt.Errorf("segment %d of %s is gone", i+1, cache.bvID)
+			}
+			return urls[i], nil
+		})
+	}
+	return sources, nil
+}
+
+// isExpired reports whether the download failed since the url expired.
+func isExpired(err error) bool {
+	code, ok := err.(errors.ErrUnexpectedStatusCode)
+	return ok && (code == http.StatusForbidden || code == http.StatusNotFound)
+}
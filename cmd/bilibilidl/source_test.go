@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/misssonder/bilibili/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlayUrlCache(t *testing.T) {
+	resp := &bilibili.PlayUrlResp{}
+	resp.Data.Dash.Video = []bilibili.DashVideo{
+		{ID: int(bilibili.Qn1080P), Codecid: 7, BaseURL: "avc"},
+		{ID: int(bilibili.Qn1080P), Codecid: 12, BaseURL: "hevc"},
+		{ID: int(bilibili.Qn720P), Codecid: 7, BaseURL: "720"},
+	}
+	cache := newPlayUrlCache("BV1", 1, 0, bilibili.FnvalDashAll, resp)
+
+	url, err := cache.dashVideoSource(bilibili.Qn1080P, 12)(false)
+	assert.NoError(t, err)
+	assert.Equal(t, "hevc", url)
+	url, err = cache.dashVideoSource(bilibili.Qn1080P, 13)(false)
+	assert.NoError(t, err)
+	assert.Equal(t, "avc", url)
+	url, err = cache.dashVideoSource(bilibili.Qn720P, 0)(false)
+	assert.NoError(t, err)
+	assert.Equal(t, "720", url)
+
+	// a forgotten response is asked for again by the next get
+	cache.forget()
+	assert.Nil(t, cache.resp)
+}
+
+func TestIsExpired(t *testing.T) {
+	assert.True(t, isExpired(errors.ErrUnexpectedStatusCode(403)))
+	assert.False(t, isExpired(errors.ErrUnexpectedStatusCode(500)))
+	assert.False(t, isExpired(fmt.Errorf("EOF")))
+	assert.False(t, isExpired(nil))
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/misssonder/bilibili/internal/filename"
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+)
+
+const (
+	steinGraphJSON = "graph.json"
+	steinGraphDot  = "graph.dot"
+)
+
+// SteinGraph is the branching structure of an interactive video, Nodes start with the root.
+type SteinGraph struct {
+	BvID         string
+	Title        string
+	GraphVersion int64
+	Variables    []SteinVariable
+	Nodes        []SteinNode
+}
+
+// SteinVariable is a variable the conditions of the choices check.
+type SteinVariable struct {
+	ID    string
+	Name  string
+	Value float64
+	Shown bool
+}
+
+type SteinNode struct {
+	EdgeID    int64
+	CID       int64
+	Title     string
+	Leaf      bool
+	Questions []SteinQuestion
+	// Output is the file of the node relative to the graph.
+	Output string
+}
+
+// SteinQuestion is shown Start after the node starts, and waits Duration for a choice, -1 for no limit.
+type SteinQuestion struct {
+	Title    string
+	Start    time.Duration
+	Duration time.Duration
+	Choices  []SteinChoice
+}
+
+// SteinChoice leads to the node of EdgeID when Condition holds, Action updates the variables.
+type SteinChoice struct {
+	EdgeID    int64
+	Option    string
+	Condition string
+	Action    string
+	Default   bool
+	Hidden    bool
+}
+
+type steinFetcher func(edgeID int64) (*bilibili.SteinEdgeInfoResp, error)
+
+// walkSteinGraph visits every node reachable from the root in breadth first order.
+// The conditions are kept but not evaluated, so a node behind a condition which never holds is visited as well.
+func walkSteinGraph(info *VideoInfo, graphVersion int64, fetch steinFetcher) (*SteinGraph, error) {
+	graph := &SteinGraph{BvID: info.BvID, Title: info.Title, GraphVersion: graphVersion}
+	variables := make(map[string]bool)
+	cids := map[int64]int64{0: info.Pages[0].CID}
+	queue := []int64{0}
+	for len(queue) != 0 {
+		if isCanceled() {
+			return nil, ctx.Err()
+		}
+		edgeID := queue[0]
+		queue = queue[1:]
+		resp, err := fetch(edgeID)
+		if err != nil {
+			return nil, fmt.Errorf("get the node %d of %s: %w", edgeID, info.BvID, err)
+		}
+		// the root is asked for by 0 and replies with its own edge id
+		if edgeID == 0 {
+			edgeID = resp.Data.EdgeID
+			cids[edgeID] = cids[0]
+		}
+		node := SteinNode{
+			EdgeID: edgeID,
+			CID:    cids[edgeID],
+			Title:  resp.Data.Title,
+			Leaf:   resp.Data.IsLeaf == 1,
+		}
+		for _, story := range resp.Data.StoryList {
+			if story.IsCurrent == 1 && story.Cid != 0 {
+				node.CID = story.Cid
+			}
+		}
+		for _, q := range resp.Data.Edges.Questions {
+			question := SteinQuestion{
+				Title:    q.Title,
+				Start:    time.Duration(q.StartTimeR) * time.Second,
+				Duration: time.Duration(q.Duration) * time.Millisecond,
+			}
+			if q.Duration < 0 {
+				question.Duration = -1
+			}
+			for _, c := range q.Choices {
+				question.Choices = append(question.Choices, SteinChoice{
+					EdgeID:    c.ID,
+					Option:    c.Option,
+					Condition: c.Condition,
+					Action:    c.NativeAction,
+					Default:   c.IsDefault == 1,
+					Hidden:    c.IsHidden == 1,
+				})
+				if _, ok := cids[c.ID]; !ok {
+					cids[c.ID] = c.Cid
+					queue = append(queue, c.ID)
+				}
+			}
+			node.Questions = append(node.Questions, question)
+		}
+		for _, v := range resp.Data.HiddenVars {
+			id := v.IDV2
+			if len(id) == 0 {
+				id = v.ID
+			}
+			if variables[id] {
+				continue
+			}
+			variables[id] = true
+			graph.Variables = append(graph.Variables, SteinVariable{ID: id, Name: v.Name, Value: v.Value, Shown: v.IsShow == 1})
+		}
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	return graph, nil
+}
+
+func writeSteinJSON(w io.Writer, graph *SteinGraph) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(graph)
+}
+
+// writeSteinDot writes the graph in the graphviz dot language, the hidden choices are dotted.
+func writeSteinDot(w io.Writer, graph *SteinGraph) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", strconv.Quote(graph.BvID))
+	fmt.Fprintf(&b, "  label=%s;\n", strconv.Quote(graph.Title))
+	for i, node := range graph.Nodes {
+		attrs := ""
+		if node.Leaf {
+			attrs = ", peripheries=2"
+		}
+		fmt.Fprintf(&b, "  e%d [label=%s%s];\n", node.EdgeID, strconv.Quote(fmt.Sprintf("%d. %s", i+1, node.Title)), attrs)
+	}
+	for _, node := range graph.Nodes {
+		for _, question := range node.Questions {
+			for _, choice := range question.Choices {
+				label := choice.Option
+				if len(choice.Condition) != 0 {
+					label += "\n[" + choice.Condition + "]"
+				}
+				if len(choice.Action) != 0 {
+					label += "\n{" + choice.Action + "}"
+				}
+				attrs := ""
+				if choice.Hidden {
+					attrs = ", style=dotted"
+				}
+				fmt.Fprintf(&b, "  e%d -> e%d [label=%s%s];\n", node.EdgeID, choice.EdgeID, strconv.Quote(label), attrs)
+			}
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// downloadSteinGate downloads every node of the interactive video into a directory named by -o,
+// along with the graph as graph.json and graph.dot.
+func downloadSteinGate(info *VideoInfo) error {
+	if err := checkFFmpeg(); err != nil {
+		return err
+	}
+	root := info.Pages[0]
+	playerInfo, err := client.PlayerInfo(info.BvID, root.CID)
+	if err != nil {
+		return err
+	}
+	graphVersion := playerInfo.Data.Interaction.GraphVersion
+	graph, err := walkSteinGraph(info, graphVersion, func(edgeID int64) (*bilibili.SteinEdgeInfoResp, error) {
+		return client.SteinEdgeInfo(info.BvID, graphVersion, edgeID)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s is an interactive video of %d nodes.\n", info.Title, len(graph.Nodes))
+
+	format, err := selectFormat()
+	if err != nil {
+		return err
+	}
+	qn, fnval := playUrlParams(format)
+	first, err := newPlayUrlCache(info.BvID, graph.Nodes[0].CID, qn, fnval, nil).get(false)
+	if err != nil {
+		return err
+	}
+	values := videoValues(info, root)
+	values.Part = info.Title
+	streams, err := selectPageStreams(info.BvID, format, first, &values)
+	if err != nil {
+		return err
+	}
+	base, err := resolveOutput(outputDir, outputTemplate(), values, "")
+	if err != nil {
+		return err
+	}
+	dir := strings.TrimSuffix(base, ".mp4")
+	if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	pending, err := steinPending(info.AID, graph, dir, values, streams.videoQuality)
+	if err != nil {
+		return err
+	}
+	// the graph is written first, so it is kept even if a node fails
+	if err = writeSteinFile(path.Join(dir, steinGraphJSON), graph, writeSteinJSON); err != nil {
+		return err
+	}
+	if err = writeSteinFile(path.Join(dir, steinGraphDot), graph, writeSteinDot); err != nil {
+		return err
+	}
+	fmt.Printf("The graph is saved to %s.\n", path.Join(dir, steinGraphJSON))
+
+	tags := videoTags(info, root)
+	tags.Album = info.Title
+	for n, i := range pending {
+		if isCanceled() {
+			return ctx.Err()
+		}
+		node := graph.Nodes[i]
+		output := path.Join(dir, node.Output)
+		fmt.Printf("Downloading node %d/%d %s\n", n+1, len(pending), node.Title)
+		cache := newPlayUrlCache(info.BvID, node.CID, qn, fnval, nil)
+		playUrlResp, err := cache.get(false)
+		if err != nil {
+			return err
+		}
+		size := segmentsSize(playUrlResp)
+		if format != bilibili.FnvalMP4 {
+			size = streamSize(playUrlResp, streams.videoQuality) + streamSize(playUrlResp, streams.audioQuality)
+		}
+		if err = checkDiskSpace(dir, size*2); err != nil {
+			return err
+		}
+		part, err := tempOutput(output)
+		if err != nil {
+			return err
+		}
+		expected := time.Duration(playUrlResp.Data.Timelength) * time.Millisecond
+		if err = downloadPage(cache, part, streams, expected); err != nil {
+			os.Remove(part)
+			return err
+		}
+		if err = os.Rename(part, output); err != nil {
+			os.Remove(part)
+			return err
+		}
+		tags.Title = node.Title
+		embedMetadata(output, tags)
+		recordArchive(info.AID, node.CID, streams.videoQuality, output)
+		if err = runHooks(newHookValues(output, steinValues(values, i, node), expected)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// steinPending sets the outputs of the nodes and returns the indexes of the ones to download.
+// The nodes reached by different edges may share a cid, it is downloaded once and they all point at its output.
+func steinPending(aid int, graph *SteinGraph, dir string, values filename.Values, qn bilibili.Qn) ([]int, error) {
+	pending := make([]int, 0, len(graph.Nodes))
+	outputs := make(map[int64]string)
+	for i := range graph.Nodes {
+		node := &graph.Nodes[i]
+		if output, ok := outputs[node.CID]; ok {
+			node.Output = output
+			continue
+		}
+		if location, ok := lookupArchived(aid, node.CID, qn); ok {
+			node.Output = path.Base(location)
+		} else {
+			name, err := resolveOutput(dir, "{page} {part}", steinValues(values, i, *node), ".mp4")
+			if err != nil {
+				return nil, err
+			}
+			node.Output = path.Base(filename.Unique(name))
+			pending = append(pending, i)
+		}
+		outputs[node.CID] = node.Output
+	}
+	return pending, nil
+}
+
+// steinValues are the file name values of the node of index.
+func steinValues(values filename.Values, index int, node SteinNode) filename.Values {
+	values.Part, values.Page, values.CID = node.Title, index+1, node.CID
+	return values
+}
+
+func writeSteinFile(name string, graph *SteinGraph, write func(w io.Writer, graph *SteinGraph) error) error {
+	tmp, err := tempOutput(name)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err = write(file, graph); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/misssonder/bilibili/internal/filename"
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func steinEdge(edgeID int64, title string, leaf bool, choices ...bilibili.SteinChoice) *bilibili.SteinEdgeInfoResp {
+	resp := &bilibili.SteinEdgeInfoResp{}
+	resp.Data.EdgeID = edgeID
+	resp.Data.Title = title
+	if leaf {
+		resp.Data.IsLeaf = 1
+	}
+	if len(choices) != 0 {
+		resp.Data.Edges.Questions = []bilibili.SteinQuestion{{Title: "?", StartTimeR: 30, Duration: -1, Choices: choices}}
+	}
+	resp.Data.HiddenVars = []bilibili.SteinVariable{{IDV2: "$a", Name: "好感度", Value: 1, IsShow: 1}}
+	return resp
+}
+
+func TestWalkSteinGraph(t *testing.T) {
+	edges := map[int64]*bilibili.SteinEdgeInfoResp{
+		0: steinEdge(1, "开始", false,
+			bilibili.SteinChoice{ID: 2, Cid: 200, Option: "左", NativeAction: "$a=$a+1", IsDefault: 1},
+			bilibili.SteinChoice{ID: 3, Cid: 300, Option: "右", Condition: "$a>=2", IsHidden: 1}),
+		2: steinEdge(2, "左边", false, bilibili.SteinChoice{ID: 3, Cid: 300, Option: "继续"}),
+		3: steinEdge(3, "结局", true),
+	}
+	fetched := make([]int64, 0)
+	info := &VideoInfo{BvID: "BV1xx411c7mD", Title: "互动视频", Pages: []Page{{CID: 100}}}
+	graph, err := walkSteinGraph(info, 7, func(edgeID int64) (*bilibili.SteinEdgeInfoResp, error) {
+		fetched = append(fetched, edgeID)
+		return edges[edgeID], nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{0, 2, 3}, fetched)
+	assert.Equal(t, int64(7), graph.GraphVersion)
+	assert.Equal(t, []SteinVariable{{ID: "$a", Name: "好感度", Value: 1, Shown: true}}, graph.Variables)
+	assert.Len(t, graph.Nodes, 3)
+	assert.Equal(t, SteinNode{
+		EdgeID: 1,
+		CID:    100,
+		Title:  "开始",
+		Questions: []SteinQuestion{{
+			Title:    "?",
+			Start:    30 * time.Second,
+			Duration: -1,
+			Choices: []SteinChoice{
+				{EdgeID: 2, Option: "左", Action: "$a=$a+1", Default: true},
+				{EdgeID: 3, Option: "右", Condition: "$a>=2", Hidden: true},
+			},
+		}},
+	}, graph.Nodes[0])
+	assert.Equal(t, int64(200), graph.Nodes[1].CID)
+	assert.Equal(t, int64(300), graph.Nodes[2].CID)
+	assert.True(t, graph.Nodes[2].Leaf)
+
+	var dot strings.Builder
+	assert.NoError(t, writeSteinDot(&dot, graph))
+	assert.Equal(t, `digraph "BV1xx411c7mD" {
+  label="互动视频";
+  e1 [label="1. 开始"];
+  e2 [label="2. 左边"];
+  e3 [label="3. 结局", peripheries=2];
+  e1 -> e2 [label="左\n{$a=$a+1}"];
+  e1 -> e3 [label="右\n[$a>=2]", style=dotted];
+  e2 -> e3 [label="继续"];
+}
+`, dot.String())
+
+	var b strings.Builder
+	assert.NoError(t, writeSteinJSON(&b, graph))
+	decoded := &SteinGraph{}
+	assert.NoError(t, json.Unmarshal([]byte(b.String()), decoded))
+	assert.Equal(t, graph, decoded)
+}
+
+func TestSteinPending(t *testing.T) {
+	// the edges 2 and 3 lead to the same video
+	graph := &SteinGraph{Nodes: []SteinNode{
+		{EdgeID: 1, CID: 100, Title: "开始"},
+		{EdgeID: 2, CID: 200, Title: "左边"},
+		{EdgeID: 3, CID: 200, Title: "右边"},
+		{EdgeID: 4, CID: 300, Title: "结局"},
+	}}
+	pending, err := steinPending(1, graph, t.TempDir(), filename.Values{}, bilibili.Qn1080P)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 3}, pending)
+	assert.Equal(t, "2 左边.mp4", graph.Nodes[1].Output)
+	assert.Equal(t, graph.Nodes[1].Output, graph.Nodes[2].Output)
+	assert.Equal(t, "4 结局.mp4", graph.Nodes[3].Output)
+}
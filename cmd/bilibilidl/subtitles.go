@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/misssonder/bilibili/pkg/progress"
+	"github.com/misssonder/bilibili/pkg/subtitle"
+)
+
+var (
+	subtitleLanguages string
+	subtitleFormat    string
+	embedSubtitles    bool
+)
+
+// subtitleFile is a downloaded subtitle track.
+type subtitleFile struct {
+	Path  string
+	Track bilibili.SubtitleTrack
+}
+
+func checkSubtitleFormat() error {
+	for _, format := range subtitle.Formats {
+		if format == subtitleFormat {
+			return nil
+		}
+	}
+	return subtitle.ErrInvalidFormat(subtitleFormat)
+}
+
+// selectSubtitleTracks selects the tracks by the --subtitles value: all, cc for the ones not generated by AI,
+// or the comma separated languages, zh matches zh-CN, zh-Hans and ai-zh.
+func selectSubtitleTracks(tracks []bilibili.SubtitleTrack, languages string) []bilibili.SubtitleTrack {
+	selected := make([]bilibili.SubtitleTrack, 0, len(tracks))
+	for _, track := range tracks {
+		for _, language := range strings.Split(languages, ",") {
+			language = strings.TrimSpace(language)
+			lan := strings.TrimPrefix(track.Lan, "ai-")
+			if language == "all" ||
+				language == "cc" && !track.IsAI() ||
+				strings.EqualFold(language, track.Lan) ||
+				strings.EqualFold(language, lan) ||
+				strings.HasPrefix(strings.ToLower(lan), strings.ToLower(language)+"-") {
+				selected = append(selected, track)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+func subtitleCues(body *bilibili.SubtitleBody) []subtitle.Cue {
+	cues := make([]subtitle.Cue, 0, len(body.Body))
+	for _, line := range body.Body {
+		cues = append(cues, subtitle.Cue{
+			From:    time.Duration(line.From * float64(time.Second)),
+			To:      time.Duration(line.To * float64(time.Second)),
+			Content: line.Content,
+			Top:     line.Location == 8,
+		})
+	}
+	return cues
+}
+
+// subtitlePath is the subtitle next to output, like video.zh-CN.srt.
+func subtitlePath(output string, track bilibili.SubtitleTrack) string {
+	return fmt.Sprintf("%s.%s.%s", strings.TrimSuffix(output, path.Ext(output)), track.Lan, subtitleFormat)
+}
+
+// downloadSubtitles saves the selected subtitles of the page next to output and muxes them into output with --embed-subs.
+// Failures are reported only, the video is downloaded already.
+func downloadSubtitles(bvID string, cid int64, output string) {
+	if len(subtitleLanguages) == 0 {
+		return
+	}
+	info, err := client.PlayerInfo(bvID, cid)
+	if err != nil {
+		fmt.Printf("Get the subtitles of %s failed: %v\n", bvID, err)
+		return
+	}
+	tracks := selectSubtitleTracks(info.Data.Subtitle.Subtitles, subtitleLanguages)
+	if len(tracks) == 0 {
+		fmt.Printf("No subtitle of %s matches %s.\n", bvID, subtitleLanguages)
+		return
+	}
+	files := make([]subtitleFile, 0, len(tracks))
+	for _, track := range tracks {
+		name, err := writeSubtitle(track, output)
+		if err != nil {
+			fmt.Printf("Download the %s subtitle of %s failed: %v\n", track.LanDoc, bvID, err)
+			continue
+		}
+		fmt.Printf("%s is saved.\n", name)
+		files = append(files, subtitleFile{Path: name, Track: track})
+	}
+	if !embedSubtitles || len(files) == 0 {
+		return
+	}
+	if err = muxSubtitles(output, files); err != nil {
+		fmt.Printf("Embed the subtitles into %s failed, they are kept next to it: %v\n", output, err)
+		return
+	}
+	for _, file := range files {
+		os.Remove(file.Path)
+	}
+}
+
+func writeSubtitle(track bilibili.SubtitleTrack, output string) (string, error) {
+	body, err := client.SubtitleBody(track)
+	if err != nil {
+		return "", err
+	}
+	name := subtitlePath(output, track)
+	tmp, err := tempOutput(name)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp)
+	file, err := os.Create(tmp)
+	if err != nil {
+		return "", err
+	}
+	if err = subtitle.Write(file, subtitleFormat, subtitleCues(body)); err != nil {
+		file.Close()
+		return "", err
+	}
+	if err = file.Close(); err != nil {
+		return "", err
+	}
+	return name, os.Rename(tmp, name)
+}
+
+// muxSubtitles adds the subtitles to output as soft subtitle streams.
+func muxSubtitles(output string, files []subtitleFile) error {
+	if err := checkFFmpeg(); err != nil {
+		return err
+	}
+	args := []string{"-y", "-i", output}
+	for _, file := range files {
+		args = append(args, "-i", file.Path)
+	}
+	args = append(args, "-map", "0")
+	for i := range files {
+		args = append(args, "-map", strconv.Itoa(i+1))
+	}
+	args = append(args, "-c", "copy")
+	if strings.EqualFold(path.Ext(output), ".mp4") {
+		// mp4 only takes text subtitles as mov_text
+		args = append(args, "-c:s", "mov_text", "-strict", "experimental")
+	}
+	// the streams of output are kept, the new ones follow them
+	existing := subtitleStreams(output)
+	for i, file := range files {
+		stream := fmt.Sprintf("-metadata:s:s:%d", existing+i)
+		args = append(args, stream, "title="+file.Track.LanDoc)
+		if language, ok := iso639(file.Track.Lan); ok {
+			args = append(args, stream, "language="+language)
+		}
+	}
+	tmp, err := tempOutput(output)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	cmd := exec.CommandContext(ctx, "ffmpeg", append(args, tmp)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %s", err, stderr.String())
+	}
+	if err = os.Rename(tmp, output); err != nil {
+		return err
+	}
+	emit(progress.Event{Type: progress.EventMerged, Path: output})
+	fmt.Printf("%d subtitles are embedded into %s.\n", len(files), output)
+	return nil
+}
+
+// subtitleStreams counts the subtitle streams of the file, it is zero when ffprobe is unavailable.
+func subtitleStreams(name string) int {
+	out, err := exec.CommandContext(ctx, "ffprobe", "-v", "error", "-select_streams", "s",
+		"-show_entries", "stream=index", "-of", "csv=p=0", name).Output()
+	if err != nil {
+		return 0
+	}
+	return len(strings.Fields(string(out)))
+}
+
+// iso639 maps the language of bilibili like zh-CN or ai-en to the ISO 639-2 code the containers take.
+func iso639(lan string) (string, bool) {
+	lan = strings.TrimPrefix(lan, "ai-")
+	if i := strings.IndexByte(lan, '-'); i >= 0 {
+		lan = lan[:i]
+	}
+	code, ok := iso639Codes[strings.ToLower(lan)]
+	return code, ok
+}
+
+var iso639Codes = map[string]string{
+	"zh": "chi",
+	"en": "eng",
+	"ja": "jpn",
+	"ko": "kor",
+	"es": "spa",
+	"fr": "fre",
+	"de": "ger",
+	"ru": "rus",
+	"pt": "por",
+	"it": "ita",
+	"ar": "ara",
+	"id": "ind",
+	"th": "tha",
+	"vi": "vie",
+	"ms": "may",
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectSubtitleTracks(t *testing.T) {
+	tracks := []bilibili.SubtitleTrack{
+		{Lan: "zh-CN", Type: 0},
+		{Lan: "ai-zh", Type: 1},
+		{Lan: "en-US", Type: 0},
+	}
+	lans := func(tracks []bilibili.SubtitleTrack) []string {
+		result := make([]string, 0, len(tracks))
+		for _, track := range tracks {
+			result = append(result, track.Lan)
+		}
+		return result
+	}
+	assert.Equal(t, []string{"zh-CN", "ai-zh", "en-US"}, lans(selectSubtitleTracks(tracks, "all")))
+	assert.Equal(t, []string{"zh-CN", "en-US"}, lans(selectSubtitleTracks(tracks, "cc")))
+	assert.Equal(t, []string{"zh-CN", "ai-zh"}, lans(selectSubtitleTracks(tracks, "zh")))
+	assert.Equal(t, []string{"ai-zh", "en-US"}, lans(selectSubtitleTracks(tracks, "ai-zh, en-us")))
+}
+
+func TestIso639(t *testing.T) {
+	code, ok := iso639("ai-zh")
+	assert.True(t, ok)
+	assert.Equal(t, "chi", code)
+	code, ok = iso639("en-US")
+	assert.True(t, ok)
+	assert.Equal(t, "eng", code)
+	_, ok = iso639("xx")
+	assert.False(t, ok)
+}
+
+func TestSubtitlePath(t *testing.T) {
+	defer func(format string) { subtitleFormat = format }(subtitleFormat)
+	subtitleFormat = "ass"
+	assert.Equal(t, "videos/a.zh-CN.ass", subtitlePath("videos/a.mp4", bilibili.SubtitleTrack{Lan: "zh-CN"}))
+}
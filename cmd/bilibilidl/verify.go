@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/misssonder/bilibili/pkg/mp4"
+	"github.com/misssonder/bilibili/pkg/progress"
+)
+
+// verifyRetries is how many times a download failing the verification is downloaded again.
+const verifyRetries = 2
+
+type errIncompleteDownload struct {
+	expected int64
+	actual   int64
+}
+
+func (err errIncompleteDownload) Error() string {
+	return fmt.Sprintf("incomplete download: expected %d bytes, got %d bytes", err.expected, err.actual)
+}
+
+// verifyMedia checks that the media file is complete and lasts as long as expected, a zero expected skips the duration check.
+func verifyMedia(name string, expected time.Duration) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	magic := make([]byte, 3)
+	if _, err = io.ReadFull(file, magic); err != nil {
+		return fmt.Errorf("%s is too short: %w", name, err)
+	}
+	if bytes.Equal(magic, []byte("FLV")) {
+		// only the size of flv segments is verified
+		return nil
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	info, err := mp4.Inspect(file, stat.Size())
+	if err != nil {
+		return err
+	}
+	if expected == 0 || info.Duration == 0 {
+		return nil
+	}
+	diff := info.Duration - expected
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff > 2*time.Second+expected/100 {
+		return fmt.Errorf("%s lasts %s, but %s is expected", name, info.Duration, expected)
+	}
+	return nil
+}
+
+// downloadVerifiedMedia downloads the stream into file and verifies it, a broken download is downloaded again
+// and an expired url is refreshed.
+func downloadVerifiedMedia(title string, source mediaSource, file *os.File, expected time.Duration) error {
+	var err error
+	for i := 0; i <= verifyRetries; i++ {
+		if i > 0 {
+			fmt.Printf("%s is broken (%v), downloading again...\n", title, err)
+			emit(progress.Event{Type: progress.EventRetry, Title: title, Path: file.Name(), Attempt: i + 1, Error: err.Error()})
+		}
+		url, sourceErr := source(i > 0 && isExpired(err))
+		if sourceErr != nil {
+			return sourceErr
+		}
+		if err = file.Truncate(0); err != nil {
+			return err
+		}
+		if _, err = file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if err = downloadMedia(title, url, file); err != nil {
+			if isCanceled() {
+				return err
+			}
+			continue
+		}
+		if err = verifyMedia(file.Name(), expected); err == nil {
+			return nil
+		}
+	}
+	return err
+}
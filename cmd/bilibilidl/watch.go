@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// liveWatcher polls the rooms and records a room whenever it goes live, a room is polled again after its recording ends.
+type liveWatcher struct {
+	ctx      context.Context
+	ids      []int64
+	interval time.Duration
+	isLive   func(id int64) (bool, error)
+	// record records the room until the live ends or ctx is canceled
+	record func(id int64) error
+}
+
+// watch polls the rooms until ctx is canceled, then it waits for the recordings to save their files.
+func (w *liveWatcher) watch() {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	recording := make(map[int64]bool)
+	// every room has a recording at most, so the ended ones never block
+	ended := make(chan int64, len(w.ids))
+	fmt.Printf("Watching %d rooms every %s.\n", len(w.ids), w.interval)
+	for {
+		for _, id := range w.ids {
+			if recording[id] || w.ctx.Err() != nil {
+				continue
+			}
+			live, err := w.isLive(id)
+			if err != nil {
+				fmt.Printf("Check room %d failed: %v\n", id, err)
+				continue
+			}
+			if !live {
+				continue
+			}
+			fmt.Printf("Room %d goes live.\n", id)
+			recording[id] = true
+			wg.Add(1)
+			go func(id int64) {
+				defer wg.Done()
+				if err := w.record(id); err != nil {
+					fmt.Printf("Record room %d failed: %v\n", id, err)
+				}
+				ended <- id
+			}(id)
+		}
+
+		timer := time.NewTimer(w.interval)
+	wait:
+		for {
+			select {
+			case <-w.ctx.Done():
+				timer.Stop()
+				return
+			case id := <-ended:
+				recording[id] = false
+			case <-timer.C:
+				break wait
+			}
+		}
+	}
+}
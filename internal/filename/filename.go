@@ -0,0 +1,201 @@
+package filename
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxNameBytes is the length limit of one path component, most file systems allow 255 bytes.
+const MaxNameBytes = 240
+
+// Values are the variables which can be referenced by a template.
+type Values struct {
+	Title        string
+	Part         string
+	Page         int
+	BvID         string
+	AID          int
+	CID          int64
+	Uploader     string
+	Mid          int
+	Pubdate      time.Time
+	Quality      string
+	AudioQuality string
+	Codec        string
+	Episode      string
+	// Room and Start are the live room and when its broadcast starts.
+	Room  int64
+	Start time.Time
+	// AuID and Album are the song of the music area and its menu.
+	AuID  int64
+	Album string
+}
+
+func (values Values) lookup(name, layout string) (string, error) {
+	switch name {
+	case "title":
+		return values.Title, nil
+	case "part":
+		return values.Part, nil
+	case "page":
+		return strconv.Itoa(values.Page), nil
+	case "bvid":
+		return values.BvID, nil
+	case "aid":
+		return strconv.Itoa(values.AID), nil
+	case "cid":
+		return strconv.FormatInt(values.CID, 10), nil
+	case "uploader":
+		return values.Uploader, nil
+	case "mid":
+		return strconv.Itoa(values.Mid), nil
+	case "pubdate":
+		if len(layout) == 0 {
+			layout = "2006-01-02"
+		}
+		return values.Pubdate.Format(layout), nil
+	case "quality":
+		return values.Quality, nil
+	case "audio_quality":
+		return values.AudioQuality, nil
+	case "codec":
+		return values.Codec, nil
+	case "episode":
+		return values.Episode, nil
+	case "room":
+		return strconv.FormatInt(values.Room, 10), nil
+	case "start":
+		if len(layout) == 0 {
+			layout = "2006-01-02 15-04-05"
+		}
+		return values.Start.Format(layout), nil
+	case "auid":
+		return strconv.FormatInt(values.AuID, 10), nil
+	case "album":
+		return values.Album, nil
+	default:
+		return "", fmt.Errorf("unknown template variable: {%s}", name)
+	}
+}
+
+// Expand replaces the {name} and {name:layout} variables of template with values.
+// Every value is sanitised on its own before it is substituted, so only the text written in template
+// separates directories: a value like ".." becomes "_" and an empty value never makes the path absolute.
+func Expand(template string, values Values) (string, error) {
+	absolute := strings.HasPrefix(strings.ReplaceAll(template, "\\", "/"), "/")
+	var builder strings.Builder
+	for len(template) != 0 {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			builder.WriteString(template)
+			break
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unclosed template variable: %s", template[start:])
+		}
+		end += start
+		builder.WriteString(template[:start])
+		name, layout := template[start+1:end], ""
+		if i := strings.IndexByte(name, ':'); i >= 0 {
+			name, layout = name[:i], name[i+1:]
+		}
+		value, err := values.lookup(name, layout)
+		if err != nil {
+			return "", err
+		}
+		if len(value) != 0 {
+			builder.WriteString(Sanitize(value))
+		}
+		template = template[end+1:]
+	}
+
+	expanded := strings.ReplaceAll(builder.String(), "\\", "/")
+	elems := strings.Split(expanded, "/")
+	for i, elem := range elems {
+		if len(elem) == 0 || elem == "." || elem == ".." {
+			continue
+		}
+		elems[i] = Sanitize(elem)
+	}
+	if absolute {
+		return "/" + path.Join(elems...), nil
+	}
+	return path.Join(elems...), nil
+}
+
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// Sanitize makes name a valid file name on Windows, macOS and Linux.
+func Sanitize(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			return -1
+		case strings.ContainsRune(`<>:"/\|?*`, r):
+			return '_'
+		default:
+			return r
+		}
+	}, name)
+	name = strings.TrimSpace(name)
+	name = strings.TrimRight(name, ". ")
+	if base := strings.ToUpper(strings.TrimSuffix(name, path.Ext(name))); reservedNames[base] {
+		name = "_" + name
+	}
+	name = Truncate(name, MaxNameBytes)
+	if len(name) == 0 {
+		return "_"
+	}
+	return name
+}
+
+// Truncate shortens name to at most max bytes without breaking a rune, keeping its extension.
+func Truncate(name string, max int) string {
+	if len(name) <= max {
+		return name
+	}
+	ext := path.Ext(name)
+	if len(ext) >= max || utf8.RuneCountInString(ext) > 16 {
+		ext = ""
+	}
+	base := name[:len(name)-len(ext)]
+	limit := max - len(ext)
+	for limit > 0 && !utf8.RuneStart(base[limit]) {
+		limit--
+	}
+	return strings.TrimRight(base[:limit], ". ") + ext
+}
+
+// Unique returns p if nothing exists there, otherwise p with the first free " (n)" suffix.
+func Unique(p string) string {
+	return UniqueReserved(p, nil)
+}
+
+// UniqueReserved is Unique treating the paths in reserved as existing, like the outputs planned before p.
+func UniqueReserved(p string, reserved map[string]bool) string {
+	free := func(p string) bool {
+		_, err := os.Stat(p)
+		return os.IsNotExist(err) && !reserved[p]
+	}
+	if free(p) {
+		return p
+	}
+	ext := path.Ext(p)
+	base := strings.TrimSuffix(p, ext)
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if free(candidate) {
+			return candidate
+		}
+	}
+}
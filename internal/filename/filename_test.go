@@ -0,0 +1,80 @@
+package filename
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpand(t *testing.T) {
+	values := Values{
+		Title:    "AC/DC: Live?",
+		Part:     "P1",
+		Page:     1,
+		BvID:     "BV1gs411B7y4",
+		Uploader: "uploader",
+		Pubdate:  time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+	name, err := Expand("{uploader}/{pubdate:20060102} {title}-{page}.mp4", values)
+	assert.NoError(t, err)
+	assert.Equal(t, "uploader/20230102 AC_DC_ Live_-1.mp4", name)
+
+	name, err = Expand("/tmp/{bvid}", values)
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/BV1gs411B7y4", name)
+
+	name, err = Expand("{pubdate}", values)
+	assert.NoError(t, err)
+	assert.Equal(t, "2023-01-02", name)
+
+	values.Room, values.Start = 21452505, time.Date(2023, 1, 2, 20, 30, 0, 0, time.UTC)
+	name, err = Expand("{room} {uploader} {start}", values)
+	assert.NoError(t, err)
+	assert.Equal(t, "21452505 uploader 2023-01-02 20-30-00", name)
+
+	values.AuID, values.Album = 1234, "menu"
+	name, err = Expand("{album}/{page} {title} au{auid}", values)
+	assert.NoError(t, err)
+	assert.Equal(t, "menu/1 AC_DC_ Live_ au1234", name)
+
+	// only the template creates directories
+	values.Title, values.Part, values.Uploader = "..", "p", "."
+	name, err = Expand("{title}/{part}", values)
+	assert.NoError(t, err)
+	assert.Equal(t, "_/p", name)
+	name, err = Expand("{uploader}/{part}", values)
+	assert.NoError(t, err)
+	assert.Equal(t, "_/p", name)
+	values.Album = ""
+	name, err = Expand("{album}/{page} {part}", values)
+	assert.NoError(t, err)
+	assert.Equal(t, "1 p", name)
+
+	_, err = Expand("{unknown}", values)
+	assert.Error(t, err)
+	_, err = Expand("{title", values)
+	assert.Error(t, err)
+}
+
+func TestSanitize(t *testing.T) {
+	assert.Equal(t, "_con.mp4", Sanitize("con.mp4"))
+	assert.Equal(t, "a_b", Sanitize("a|b. "))
+	assert.Equal(t, "_", Sanitize("..."))
+	long := Sanitize(strings.Repeat("视频", 100) + ".mp4")
+	assert.LessOrEqual(t, len(long), MaxNameBytes)
+	assert.True(t, strings.HasSuffix(long, "频.mp4"))
+}
+
+func TestUnique(t *testing.T) {
+	dir := t.TempDir()
+	p := path.Join(dir, "video.mp4")
+	assert.Equal(t, p, Unique(p))
+	assert.NoError(t, os.WriteFile(p, nil, 0644))
+	assert.Equal(t, path.Join(dir, "video (1).mp4"), Unique(p))
+	reserved := map[string]bool{path.Join(dir, "video (1).mp4"): true}
+	assert.Equal(t, path.Join(dir, "video (2).mp4"), UniqueReserved(p, reserved))
+}
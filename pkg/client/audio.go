@@ -0,0 +1,228 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/misssonder/bilibili/pkg/errors"
+)
+
+const (
+	audioSongInfoUrl  = "https://www.bilibili.com/audio/music-service-c/web/song/info"
+	audioLyricUrl     = "https://www.bilibili.com/audio/music-service-c/web/song/lyric"
+	audioMenuInfoUrl  = "https://www.bilibili.com/audio/music-service-c/web/menu/info"
+	audioMenuSongsUrl = "https://www.bilibili.com/audio/music-service-c/web/song/of-menu"
+	audioStreamUrl    = "https://api.bilibili.com/audio/music-service-c/url"
+)
+
+// AudioQuality is the quality of the songs in the music area, the higher ones require the login or the vip.
+type AudioQuality int
+
+const (
+	AudioQuality128K AudioQuality = 0
+	AudioQuality192K AudioQuality = 1
+	AudioQuality320K AudioQuality = 2
+	AudioQualityFLAC AudioQuality = 3
+)
+
+// AudioQualities are the qualities from the highest.
+var AudioQualities = []AudioQuality{AudioQualityFLAC, AudioQuality320K, AudioQuality192K, AudioQuality128K}
+
+func (q AudioQuality) String() string {
+	switch q {
+	case AudioQuality128K:
+		return "128K"
+	case AudioQuality192K:
+		return "192K"
+	case AudioQuality320K:
+		return "320K"
+	case AudioQualityFLAC:
+		return "FLAC"
+	default:
+		return fmt.Sprintf("%d", int(q))
+	}
+}
+
+// AudioSong is a song of the music area, the au id of it is Id.
+type AudioSong struct {
+	Id     int64  `json:"id"`
+	Uid    int    `json:"uid"`
+	Uname  string `json:"uname"`
+	Author string `json:"author"`
+	Title  string `json:"title"`
+	Cover  string `json:"cover"`
+	Intro  string `json:"intro"`
+	// Lyric is the url of the lrc file, it is empty without the lyrics.
+	Lyric     string `json:"lyric"`
+	Duration  int    `json:"duration"`
+	Passtime  int64  `json:"passtime"`
+	Curtime   int64  `json:"curtime"`
+	Aid       int    `json:"aid"`
+	Bvid      string `json:"bvid"`
+	Cid       int64  `json:"cid"`
+	Statistic struct {
+		Play    int `json:"play"`
+		Collect int `json:"collect"`
+		Comment int `json:"comment"`
+		Share   int `json:"share"`
+	} `json:"statistic"`
+}
+
+type AudioSongInfoResp struct {
+	Code int       `json:"code"`
+	Msg  string    `json:"msg"`
+	Data AudioSong `json:"data"`
+}
+
+type AudioLyricResp struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	// Data is the lrc text.
+	Data string `json:"data"`
+}
+
+type AudioMenuInfoResp struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data struct {
+		MenuId int64  `json:"menuId"`
+		Uid    int    `json:"uid"`
+		Uname  string `json:"uname"`
+		Title  string `json:"title"`
+		Cover  string `json:"cover"`
+		Intro  string `json:"intro"`
+		Type   int    `json:"type"`
+		Ctime  int64  `json:"ctime"`
+		Snum   int    `json:"snum"`
+	} `json:"data"`
+}
+
+type AudioMenuSongsResp struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data struct {
+		CurPage   int         `json:"curPage"`
+		PageCount int         `json:"pageCount"`
+		TotalSize int         `json:"totalSize"`
+		PageSize  int         `json:"pageSize"`
+		Data      []AudioSong `json:"data"`
+	} `json:"data"`
+}
+
+type AudioStreamResp struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data struct {
+		Sid int64 `json:"sid"`
+		// Type is the AudioQuality of the stream, it is lower than the requested one without the permission.
+		Type      AudioQuality `json:"type"`
+		Info      string       `json:"info"`
+		Timeout   int          `json:"timeout"`
+		Size      int64        `json:"size"`
+		Cdns      []string     `json:"cdns"`
+		Qualities []struct {
+			Type        AudioQuality `json:"type"`
+			Desc        string       `json:"desc"`
+			Size        int64        `json:"size"`
+			Bps         string       `json:"bps"`
+			Tag         string       `json:"tag"`
+			Require     int          `json:"require"`
+			RequireDesc string       `json:"requiredesc"`
+		} `json:"qualities"`
+		Title string `json:"title"`
+		Cover string `json:"cover"`
+	} `json:"data"`
+}
+
+// AudioSongInfo https://github.com/SocialSisterYi/bilibili-API-collect/blob/master/docs/audio/info.md
+func (client *Client) AudioSongInfo(auID int64) (*AudioSongInfoResp, error) {
+	audioSongInfoResp := &AudioSongInfoResp{}
+	if err := client.getAudioJSON(fmt.Sprintf("%s?sid=%d", audioSongInfoUrl, auID), audioSongInfoResp); err != nil {
+		return nil, err
+	}
+	if audioSongInfoResp.Code != 0 {
+		return nil, errors.StatusError{Code: audioSongInfoResp.Code, Cause: audioSongInfoResp.Msg}
+	}
+	return audioSongInfoResp, nil
+}
+
+// AudioLyric returns the lrc lyrics of the song, they are empty when the song has none.
+func (client *Client) AudioLyric(auID int64) (string, error) {
+	audioLyricResp := &AudioLyricResp{}
+	if err := client.getAudioJSON(fmt.Sprintf("%s?sid=%d", audioLyricUrl, auID), audioLyricResp); err != nil {
+		return "", err
+	}
+	if audioLyricResp.Code != 0 {
+		return "", errors.StatusError{Code: audioLyricResp.Code, Cause: audioLyricResp.Msg}
+	}
+	return audioLyricResp.Data, nil
+}
+
+// AudioStream returns the stream of the song in the quality, or in the highest permitted one below it.
+func (client *Client) AudioStream(auID int64, quality AudioQuality) (*AudioStreamResp, error) {
+	url := fmt.Sprintf("%s?songid=%d&quality=%d&privilege=2&mid=&platform=android", audioStreamUrl, auID, quality)
+	audioStreamResp := &AudioStreamResp{}
+	if err := client.getAudioJSON(url, audioStreamResp); err != nil {
+		return nil, err
+	}
+	if audioStreamResp.Code != 0 {
+		return nil, errors.StatusError{Code: audioStreamResp.Code, Cause: audioStreamResp.Msg}
+	}
+	return audioStreamResp, nil
+}
+
+func (client *Client) AudioMenuInfo(amID int64) (*AudioMenuInfoResp, error) {
+	audioMenuInfoResp := &AudioMenuInfoResp{}
+	if err := client.getAudioJSON(fmt.Sprintf("%s?sid=%d", audioMenuInfoUrl, amID), audioMenuInfoResp); err != nil {
+		return nil, err
+	}
+	if audioMenuInfoResp.Code != 0 {
+		return nil, errors.StatusError{Code: audioMenuInfoResp.Code, Cause: audioMenuInfoResp.Msg}
+	}
+	return audioMenuInfoResp, nil
+}
+
+// AudioMenuSongs returns all songs of the menu, the pages are requested one by one.
+func (client *Client) AudioMenuSongs(amID int64) ([]AudioSong, error) {
+	songs := make([]AudioSong, 0)
+	for pn := 1; ; pn++ {
+		audioMenuSongsResp := &AudioMenuSongsResp{}
+		url := fmt.Sprintf("%s?sid=%d&pn=%d&ps=100", audioMenuSongsUrl, amID, pn)
+		if err := client.getAudioJSON(url, audioMenuSongsResp); err != nil {
+			return nil, err
+		}
+		if audioMenuSongsResp.Code != 0 {
+			return nil, errors.StatusError{Code: audioMenuSongsResp.Code, Cause: audioMenuSongsResp.Msg}
+		}
+		songs = append(songs, audioMenuSongsResp.Data.Data...)
+		if pn >= audioMenuSongsResp.Data.PageCount || len(audioMenuSongsResp.Data.Data) == 0 {
+			return songs, nil
+		}
+	}
+}
+
+// getAudioJSON does not replace client.HttpClient, the other requests may be using it.
+func (client *Client) getAudioJSON(url string, v interface{}) error {
+	httpClient := &http.Client{}
+	request, err := client.newCookieRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Referer", "https://www.bilibili.com")
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
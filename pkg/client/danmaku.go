@@ -0,0 +1,109 @@
+package client
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/misssonder/bilibili/pkg/danmaku"
+	"github.com/misssonder/bilibili/pkg/dmseg"
+	"github.com/misssonder/bilibili/pkg/errors"
+)
+
+const (
+	danmakuXMLUrl     = "https://api.bilibili.com/x/v1/dm/list.so"
+	danmakuSegmentUrl = "https://api.bilibili.com/x/v2/dm/web/seg.so"
+)
+
+// DanmakuXML returns the XML danmaku list of the cid, it holds the latest danmaku up to the limit of the video.
+func (client *Client) DanmakuXML(cid int64) ([]danmaku.Danmaku, error) {
+	url := fmt.Sprintf("%s?oid=%d", danmakuXMLUrl, cid)
+	httpClient := &http.Client{}
+	request, err := client.newCookieRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+
+	// the list is deflated without asking for it
+	var reader io.Reader = resp.Body
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "deflate") {
+		flateReader := flate.NewReader(resp.Body)
+		defer flateReader.Close()
+		reader = flateReader
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return danmaku.ParseXML(bytes.NewReader(body))
+}
+
+// DanmakuSegment returns the segment of the cid at index, which starts from 1 and covers 6 minutes of the video.
+// A segment after the end of the video is empty.
+func (client *Client) DanmakuSegment(cid int64, index int) (*dmseg.DmSegMobileReply, error) {
+	url := fmt.Sprintf("%s?type=1&oid=%d&segment_index=%d", danmakuSegmentUrl, cid, index)
+	httpClient := &http.Client{}
+	request, err := client.newCookieRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	// the errors are replied in JSON instead of protobuf
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		statusResp := struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}{}
+		if err = json.Unmarshal(body, &statusResp); err != nil {
+			return nil, err
+		}
+		if statusResp.Code != 0 {
+			return nil, errors.StatusError{Code: statusResp.Code, Cause: statusResp.Message}
+		}
+		return &dmseg.DmSegMobileReply{}, nil
+	}
+	return dmseg.Unmarshal(body)
+}
+
+// Danmaku returns all danmaku of the cid by fetching every segment of the video of the duration,
+// unlike DanmakuXML it is not capped. The segments are fetched until an empty one when the duration is unknown.
+func (client *Client) Danmaku(cid int64, duration time.Duration) ([]danmaku.Danmaku, error) {
+	list := make([]danmaku.Danmaku, 0)
+	for index := 1; duration <= 0 || index <= dmseg.Segments(duration); index++ {
+		reply, err := client.DanmakuSegment(cid, index)
+		if err != nil {
+			return nil, fmt.Errorf("segment %d: %w", index, err)
+		}
+		if duration <= 0 && len(reply.Elems) == 0 {
+			break
+		}
+		list = append(list, reply.Danmaku()...)
+	}
+	danmaku.Sort(list)
+	return list, nil
+}
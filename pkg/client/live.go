@@ -0,0 +1,329 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/misssonder/bilibili/pkg/errors"
+)
+
+const (
+	liveRoomInitUrl     = "https://api.live.bilibili.com/room/v1/Room/room_init"
+	liveRoomInfoUrl     = "https://api.live.bilibili.com/room/v1/Room/get_info"
+	liveMasterInfoUrl   = "https://api.live.bilibili.com/live_user/v1/Master/info"
+	liveRoomPlayInfoUrl = "https://api.live.bilibili.com/xlive/web-room/v2/index/getRoomPlayInfo"
+	liveDanmuInfoUrl    = "https://api.live.bilibili.com/xlive/web-room/v1/index/getDanmuInfo"
+)
+
+// The live status of a room.
+const (
+	LiveStatusOffline  = 0
+	LiveStatusLive     = 1
+	LiveStatusRotating = 2
+)
+
+// The live qualities, a room accepts part of them.
+const (
+	LiveQnDolby    = 30000
+	LiveQn4k       = 20000
+	LiveQnOriginal = 10000
+	LiveQnBluRay   = 400
+	LiveQnUltra    = 250
+	LiveQnHigh     = 150
+	LiveQnSmooth   = 80
+)
+
+// The protocols and formats of the live streams.
+const (
+	LiveProtocolFLV = "http_stream"
+	LiveProtocolHLS = "http_hls"
+	LiveFormatFLV   = "flv"
+	LiveFormatTS    = "ts"
+	LiveFormatFMP4  = "fmp4"
+)
+
+type RoomInitResp struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    struct {
+		RoomID     int64 `json:"room_id"`
+		ShortID    int64 `json:"short_id"`
+		Uid        int64 `json:"uid"`
+		IsHidden   bool  `json:"is_hidden"`
+		IsLocked   bool  `json:"is_locked"`
+		IsPortrait bool  `json:"is_portrait"`
+		LiveStatus int   `json:"live_status"`
+		Encrypted  bool  `json:"encrypted"`
+		// LiveTime is the unix timestamp the live started at.
+		LiveTime int64 `json:"live_time"`
+	} `json:"data"`
+}
+
+type RoomInfoResp struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    struct {
+		Uid            int64  `json:"uid"`
+		RoomID         int64  `json:"room_id"`
+		ShortID        int64  `json:"short_id"`
+		Attention      int    `json:"attention"`
+		Online         int    `json:"online"`
+		IsPortrait     bool   `json:"is_portrait"`
+		Description    string `json:"description"`
+		LiveStatus     int    `json:"live_status"`
+		AreaName       string `json:"area_name"`
+		ParentAreaName string `json:"parent_area_name"`
+		Title          string `json:"title"`
+		UserCover      string `json:"user_cover"`
+		Keyframe       string `json:"keyframe"`
+		// LiveTime is like 2006-01-02 15:04:05 in China Standard Time, 0000-00-00 00:00:00 when offline.
+		LiveTime string `json:"live_time"`
+		Tags     string `json:"tags"`
+	} `json:"data"`
+}
+
+type MasterInfoResp struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    struct {
+		Info struct {
+			Uid   int64  `json:"uid"`
+			Uname string `json:"uname"`
+			Face  string `json:"face"`
+		} `json:"info"`
+		FollowerNum  int    `json:"follower_num"`
+		RoomID       int64  `json:"room_id"`
+		MedalName    string `json:"medal_name"`
+		RoomNews     string `json:"room_news"`
+		Announcement string `json:"announcement"`
+	} `json:"data"`
+}
+
+type RoomPlayInfoResp struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    struct {
+		RoomID      int64 `json:"room_id"`
+		ShortID     int64 `json:"short_id"`
+		Uid         int64 `json:"uid"`
+		LiveStatus  int   `json:"live_status"`
+		LiveTime    int64 `json:"live_time"`
+		Encrypted   bool  `json:"encrypted"`
+		PlayurlInfo struct {
+			Playurl struct {
+				Cid     int64 `json:"cid"`
+				GQnDesc []struct {
+					Qn   int    `json:"qn"`
+					Desc string `json:"desc"`
+				} `json:"g_qn_desc"`
+				Stream []struct {
+					ProtocolName string `json:"protocol_name"`
+					Format       []struct {
+						FormatName string `json:"format_name"`
+						Codec      []struct {
+							CodecName string `json:"codec_name"`
+							CurrentQn int    `json:"current_qn"`
+							AcceptQn  []int  `json:"accept_qn"`
+							BaseUrl   string `json:"base_url"`
+							UrlInfo   []struct {
+								Host      string `json:"host"`
+								Extra     string `json:"extra"`
+								StreamTTL int    `json:"stream_ttl"`
+							} `json:"url_info"`
+						} `json:"codec"`
+					} `json:"format"`
+				} `json:"stream"`
+			} `json:"playurl"`
+		} `json:"playurl_info"`
+	} `json:"data"`
+}
+
+type DanmuInfoResp struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    struct {
+		Group    string `json:"group"`
+		MaxDelay int    `json:"max_delay"`
+		// Token is the key of the auth packet of the chat.
+		Token    string `json:"token"`
+		HostList []struct {
+			Host    string `json:"host"`
+			Port    int    `json:"port"`
+			WssPort int    `json:"wss_port"`
+			WsPort  int    `json:"ws_port"`
+		} `json:"host_list"`
+	} `json:"data"`
+}
+
+// ChatUrls are the websocket urls of the chat servers.
+func (resp *DanmuInfoResp) ChatUrls() []string {
+	urls := make([]string, 0, len(resp.Data.HostList))
+	for _, host := range resp.Data.HostList {
+		urls = append(urls, fmt.Sprintf("wss://%s:%d/sub", host.Host, host.WssPort))
+	}
+	return urls
+}
+
+// LiveStream is a stream of the room in a protocol, format and codec, Urls are the mirrors of it.
+type LiveStream struct {
+	Protocol  string
+	Format    string
+	Codec     string
+	Qn        int
+	AcceptQns []int
+	Urls      []string
+}
+
+// Streams lists the streams of the play info.
+func (resp *RoomPlayInfoResp) Streams() []LiveStream {
+	streams := make([]LiveStream, 0)
+	for _, stream := range resp.Data.PlayurlInfo.Playurl.Stream {
+		for _, format := range stream.Format {
+			for _, codec := range format.Codec {
+				liveStream := LiveStream{
+					Protocol:  stream.ProtocolName,
+					Format:    format.FormatName,
+					Codec:     codec.CodecName,
+					Qn:        codec.CurrentQn,
+					AcceptQns: codec.AcceptQn,
+				}
+				for _, info := range codec.UrlInfo {
+					liveStream.Urls = append(liveStream.Urls, info.Host+codec.BaseUrl+info.Extra)
+				}
+				streams = append(streams, liveStream)
+			}
+		}
+	}
+	return streams
+}
+
+// QnDescription is the name of the live quality like 原画, or the number of it when it is unknown.
+func (resp *RoomPlayInfoResp) QnDescription(qn int) string {
+	for _, desc := range resp.Data.PlayurlInfo.Playurl.GQnDesc {
+		if desc.Qn == qn {
+			return desc.Desc
+		}
+	}
+	return fmt.Sprintf("%d", qn)
+}
+
+// AcceptQns are the qualities the room accepts from the highest.
+func (resp *RoomPlayInfoResp) AcceptQns() []int {
+	seen := make(map[int]bool)
+	qns := make([]int, 0)
+	for _, stream := range resp.Streams() {
+		for _, qn := range stream.AcceptQns {
+			if !seen[qn] {
+				seen[qn] = true
+				qns = append(qns, qn)
+			}
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(qns)))
+	return qns
+}
+
+// LiveStartTime parses the live time of RoomInfoResp, it is zero when the room is offline.
+func LiveStartTime(liveTime string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", liveTime, time.FixedZone("CST", 8*60*60))
+	if err != nil || t.Year() < 2000 {
+		return time.Time{}
+	}
+	return t
+}
+
+// RoomInit resolves the room of id, which is the short id or the real one.
+func (client *Client) RoomInit(id int64) (*RoomInitResp, error) {
+	roomInitResp := &RoomInitResp{}
+	if err := client.getLiveJSON(fmt.Sprintf("%s?id=%d", liveRoomInitUrl, id), roomInitResp); err != nil {
+		return nil, err
+	}
+	if roomInitResp.Code != 0 {
+		return nil, errors.StatusError{Code: roomInitResp.Code, Cause: roomInitResp.Message}
+	}
+	return roomInitResp, nil
+}
+
+func (client *Client) RoomInfo(roomID int64) (*RoomInfoResp, error) {
+	roomInfoResp := &RoomInfoResp{}
+	if err := client.getLiveJSON(fmt.Sprintf("%s?room_id=%d", liveRoomInfoUrl, roomID), roomInfoResp); err != nil {
+		return nil, err
+	}
+	if roomInfoResp.Code != 0 {
+		return nil, errors.StatusError{Code: roomInfoResp.Code, Cause: roomInfoResp.Message}
+	}
+	return roomInfoResp, nil
+}
+
+// MasterInfo returns the streamer of uid.
+func (client *Client) MasterInfo(uid int64) (*MasterInfoResp, error) {
+	masterInfoResp := &MasterInfoResp{}
+	if err := client.getLiveJSON(fmt.Sprintf("%s?uid=%d", liveMasterInfoUrl, uid), masterInfoResp); err != nil {
+		return nil, err
+	}
+	if masterInfoResp.Code != 0 {
+		return nil, errors.StatusError{Code: masterInfoResp.Code, Cause: masterInfoResp.Message}
+	}
+	return masterInfoResp, nil
+}
+
+// RoomPlayInfo returns the streams of the real room id in the quality qn, the higher qualities require the login.
+func (client *Client) RoomPlayInfo(roomID int64, qn int) (*RoomPlayInfoResp, error) {
+	url := fmt.Sprintf("%s?room_id=%d&protocol=0,1&format=0,1,2&codec=0,1&qn=%d&platform=web&ptype=8",
+		liveRoomPlayInfoUrl, roomID, qn)
+	roomPlayInfoResp := &RoomPlayInfoResp{}
+	if err := client.getLiveJSON(url, roomPlayInfoResp); err != nil {
+		return nil, err
+	}
+	if roomPlayInfoResp.Code != 0 {
+		return nil, errors.StatusError{Code: roomPlayInfoResp.Code, Cause: roomPlayInfoResp.Message}
+	}
+	return roomPlayInfoResp, nil
+}
+
+// DanmuInfo returns the chat servers of the real room id and the token to connect them.
+func (client *Client) DanmuInfo(roomID int64) (*DanmuInfoResp, error) {
+	key, err := client.wbiKey()
+	if err != nil {
+		return nil, err
+	}
+	query := signWbi(url.Values{"id": {strconv.FormatInt(roomID, 10)}, "type": {"0"}}, key, time.Now())
+	danmuInfoResp := &DanmuInfoResp{}
+	if err := client.getLiveJSON(liveDanmuInfoUrl+"?"+query, danmuInfoResp); err != nil {
+		return nil, err
+	}
+	if danmuInfoResp.Code != 0 {
+		return nil, errors.StatusError{Code: danmuInfoResp.Code, Cause: danmuInfoResp.Message}
+	}
+	return danmuInfoResp, nil
+}
+
+// getLiveJSON is called by the concurrent recorders of live watch, so the client is its own.
+func (client *Client) getLiveJSON(url string, v interface{}) error {
+	httpClient := &http.Client{}
+	request, err := client.newCookieRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Referer", "https://live.bilibili.com")
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
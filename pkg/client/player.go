@@ -0,0 +1,154 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/misssonder/bilibili/pkg/errors"
+	"github.com/misssonder/bilibili/pkg/video"
+)
+
+const (
+	playerInfoUrl = "https://api.bilibili.com/x/player/v2"
+)
+
+type PlayerInfoResp struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	TTL     int    `json:"ttl"`
+	Data    struct {
+		Aid        int         `json:"aid"`
+		Bvid       string      `json:"bvid"`
+		Cid        int64       `json:"cid"`
+		ViewPoints []ViewPoint `json:"view_points"`
+		// Interaction is only set for interactive videos.
+		Interaction struct {
+			GraphVersion int64 `json:"graph_version"`
+			Mark         int   `json:"mark"`
+		} `json:"interaction"`
+		Subtitle struct {
+			AllowSubmit bool            `json:"allow_submit"`
+			Lan         string          `json:"lan"`
+			LanDoc      string          `json:"lan_doc"`
+			Subtitles   []SubtitleTrack `json:"subtitles"`
+		} `json:"subtitle"`
+	} `json:"data"`
+}
+
+// SubtitleTrack is a CC or AI subtitle of the video, its body is at SubtitleUrl.
+type SubtitleTrack struct {
+	ID          int64  `json:"id"`
+	IDStr       string `json:"id_str"`
+	Lan         string `json:"lan"`
+	LanDoc      string `json:"lan_doc"`
+	IsLock      bool   `json:"is_lock"`
+	SubtitleUrl string `json:"subtitle_url"`
+	// Type is 0 for CC and 1 for AI subtitles.
+	Type     int `json:"type"`
+	AiType   int `json:"ai_type"`
+	AiStatus int `json:"ai_status"`
+}
+
+// IsAI reports whether the subtitle is generated by AI.
+func (track SubtitleTrack) IsAI() bool {
+	return track.Type == 1 || strings.HasPrefix(track.Lan, "ai-")
+}
+
+// SubtitleBody is the JSON body of a subtitle, From and To are in seconds.
+type SubtitleBody struct {
+	FontSize        float64 `json:"font_size"`
+	FontColor       string  `json:"font_color"`
+	BackgroundAlpha float64 `json:"background_alpha"`
+	BackgroundColor string  `json:"background_color"`
+	Stroke          string  `json:"Stroke"`
+	Body            []struct {
+		From     float64 `json:"from"`
+		To       float64 `json:"to"`
+		Sid      int     `json:"sid"`
+		Location int     `json:"location"`
+		Content  string  `json:"content"`
+		Music    float64 `json:"music"`
+	} `json:"body"`
+}
+
+// ViewPoint is a chapter (视频看点) of the video, From and To are in seconds.
+type ViewPoint struct {
+	Type    int    `json:"type"`
+	From    int    `json:"from"`
+	To      int    `json:"to"`
+	Content string `json:"content"`
+	ImgUrl  string `json:"imgUrl"`
+	LogoUrl string `json:"logoUrl"`
+}
+
+func (client *Client) PlayerInfo(bvid string, cid int64) (*PlayerInfoResp, error) {
+	id, err := video.ExtractBvID(bvid)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s?bvid=%s&cid=%d", playerInfoUrl, id, cid)
+	httpClient := &http.Client{}
+	request, err := client.newCookieRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	playerInfoResp := &PlayerInfoResp{}
+	if err = json.Unmarshal(body, playerInfoResp); err != nil {
+		return nil, err
+	}
+	if playerInfoResp.Code != 0 {
+		return nil, errors.StatusError{Code: playerInfoResp.Code, Cause: playerInfoResp.Message}
+	}
+
+	return playerInfoResp, nil
+}
+
+// SubtitleBody downloads the body of the subtitle track.
+func (client *Client) SubtitleBody(track SubtitleTrack) (*SubtitleBody, error) {
+	url := track.SubtitleUrl
+	if strings.HasPrefix(url, "//") {
+		url = "https:" + url
+	}
+	url = strings.Replace(url, "http://", "https://", 1)
+	httpClient := &http.Client{}
+	request, err := client.newCookieRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	subtitleBody := &SubtitleBody{}
+	if err = json.Unmarshal(body, subtitleBody); err != nil {
+		return nil, err
+	}
+	return subtitleBody, nil
+}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/misssonder/bilibili/pkg/errors"
+	"github.com/misssonder/bilibili/pkg/video"
+)
+
+const (
+	steinEdgeInfoUrl = "https://api.bilibili.com/x/stein/edgeinfo_v2"
+)
+
+// SteinEdgeInfoResp is a node of the graph of an interactive video, the choices of its questions lead to the next nodes.
+type SteinEdgeInfoResp struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	TTL     int    `json:"ttl"`
+	Data    struct {
+		Title     string `json:"title"`
+		EdgeID    int64  `json:"edge_id"`
+		StoryList []struct {
+			NodeID    int64  `json:"node_id"`
+			EdgeID    int64  `json:"edge_id"`
+			Title     string `json:"title"`
+			Cid       int64  `json:"cid"`
+			StartPos  int    `json:"start_pos"`
+			Cover     string `json:"cover"`
+			IsCurrent int    `json:"is_current"`
+			Cursor    int    `json:"cursor"`
+		} `json:"story_list"`
+		Edges struct {
+			Questions []SteinQuestion `json:"questions"`
+		} `json:"edges"`
+		HiddenVars []SteinVariable `json:"hidden_vars"`
+		IsLeaf     int             `json:"is_leaf"`
+	} `json:"data"`
+}
+
+// SteinQuestion is shown StartTimeR seconds after the node starts.
+type SteinQuestion struct {
+	ID         int64         `json:"id"`
+	Type       int           `json:"type"`
+	StartTimeR int           `json:"start_time_r"`
+	Duration   int           `json:"duration"`
+	PauseVideo int           `json:"pause_video"`
+	Title      string        `json:"title"`
+	Choices    []SteinChoice `json:"choices"`
+}
+
+// SteinChoice jumps to the node of edge ID when its Condition on the variables holds,
+// NativeAction updates the variables like $a=$a+1.
+type SteinChoice struct {
+	ID             int64  `json:"id"`
+	PlatformAction string `json:"platform_action"`
+	NativeAction   string `json:"native_action"`
+	Condition      string `json:"condition"`
+	Cid            int64  `json:"cid"`
+	Option         string `json:"option"`
+	IsDefault      int    `json:"is_default"`
+	IsHidden       int    `json:"is_hidden"`
+}
+
+// SteinVariable is a variable of the conditions, IDV2 is how the conditions refer to it.
+type SteinVariable struct {
+	Value         float64 `json:"value"`
+	ID            string  `json:"id"`
+	IDV2          string  `json:"id_v2"`
+	Type          int     `json:"type"`
+	IsShow        int     `json:"is_show"`
+	Name          string  `json:"name"`
+	SkipOverwrite int     `json:"skip_overwrite"`
+}
+
+// SteinEdgeInfo returns the node of edgeID in the graph of graphVersion, edgeID 0 is the root.
+func (client *Client) SteinEdgeInfo(bvid string, graphVersion, edgeID int64) (*SteinEdgeInfoResp, error) {
+	id, err := video.ExtractBvID(bvid)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s?bvid=%s&graph_version=%d", steinEdgeInfoUrl, id, graphVersion)
+	if edgeID != 0 {
+		url = fmt.Sprintf("%s&edge_id=%d", url, edgeID)
+	}
+	httpClient := &http.Client{}
+	request, err := client.newCookieRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	edgeInfoResp := &SteinEdgeInfoResp{}
+	if err = json.Unmarshal(body, edgeInfoResp); err != nil {
+		return nil, err
+	}
+	if edgeInfoResp.Code != 0 {
+		return nil, errors.StatusError{Code: edgeInfoResp.Code, Cause: edgeInfoResp.Message}
+	}
+	return edgeInfoResp, nil
+}
@@ -10,6 +10,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 )
 
@@ -110,8 +111,8 @@ type VideoInfoResp struct {
 			} `json:"dimension"`
 		} `json:"pages"`
 		Subtitle struct {
-			AllowSubmit bool          `json:"allow_submit"`
-			List        []interface{} `json:"list"`
+			AllowSubmit bool            `json:"allow_submit"`
+			List        []SubtitleTrack `json:"list"`
 		} `json:"subtitle"`
 		Staff []struct {
 			Mid   int    `json:"mid"`
@@ -201,26 +202,25 @@ type PlayUrlResp struct {
 			MinBufferTime float64     `json:"min_buffer_time"`
 			Video         []DashVideo `json:"video"`
 			Audio         []DashAudio `json:"audio"`
-			Dolby         struct {
-				Type  int         `json:"type"`
-				Audio interface{} `json:"audio"`
-			} `json:"dolby"`
-			Flac interface{} `json:"flac"`
+			Dolby         DashDolby   `json:"dolby"`
+			Flac          *DashFlac   `json:"flac"`
 		} `json:"dash"`
-		SupportFormats []struct {
-			Quality        int         `json:"quality"`
-			Format         string      `json:"format"`
-			NewDescription string      `json:"new_description"`
-			DisplayDesc    string      `json:"display_desc"`
-			Superscript    string      `json:"superscript"`
-			Codecs         interface{} `json:"codecs"`
-		} `json:"support_formats"`
-		HighFormat   interface{} `json:"high_format"`
-		LastPlayTime int         `json:"last_play_time"`
-		LastPlayCid  int         `json:"last_play_cid"`
+		SupportFormats []SupportFormat `json:"support_formats"`
+		HighFormat     interface{}     `json:"high_format"`
+		LastPlayTime   int             `json:"last_play_time"`
+		LastPlayCid    int             `json:"last_play_cid"`
 	} `json:"data"`
 }
 
+type SupportFormat struct {
+	Quality        int      `json:"quality"`
+	Format         string   `json:"format"`
+	NewDescription string   `json:"new_description"`
+	DisplayDesc    string   `json:"display_desc"`
+	Superscript    string   `json:"superscript"`
+	Codecs         []string `json:"codecs"`
+}
+
 type DashVideo struct {
 	ID           int      `json:"id"`
 	BaseURL      string   `json:"base_url"`
@@ -259,6 +259,59 @@ type DashAudio struct {
 	Codecid int `json:"codecid"`
 }
 
+// CodecName returns the short name of the video codec, e.g. avc, hevc or av1.
+func (video DashVideo) CodecName() string {
+	switch video.Codecid {
+	case 7:
+		return "avc"
+	case 12:
+		return "hevc"
+	case 13:
+		return "av1"
+	}
+	if i := strings.IndexByte(video.Codecs, '.'); i > 0 {
+		return video.Codecs[:i]
+	}
+	return video.Codecs
+}
+
+// DashDolby is the dolby atmos audio of a dash stream, Type 1 is dolby audio and 2 is dolby atmos.
+type DashDolby struct {
+	Type  int         `json:"type"`
+	Audio []DashAudio `json:"audio"`
+}
+
+// DashFlac is the Hi-Res lossless audio of a dash stream.
+type DashFlac struct {
+	Display bool       `json:"display"`
+	Audio   *DashAudio `json:"audio"`
+}
+
+// AudioStreams returns all audio streams of the dash response, including dolby and flac ones.
+func (playUrlResp *PlayUrlResp) AudioStreams() []DashAudio {
+	dash := playUrlResp.Data.Dash
+	audios := make([]DashAudio, 0, len(dash.Audio)+len(dash.Dolby.Audio)+1)
+	audios = append(audios, dash.Audio...)
+	audios = append(audios, dash.Dolby.Audio...)
+	if dash.Flac != nil && dash.Flac.Audio != nil {
+		audios = append(audios, *dash.Flac.Audio)
+	}
+	return audios
+}
+
+// SegmentURLs returns the urls of the durl segments in playback order.
+func (playUrlResp *PlayUrlResp) SegmentURLs() []string {
+	durl := playUrlResp.Data.Durl
+	sort.SliceStable(durl, func(i, j int) bool {
+		return durl[i].Order < durl[j].Order
+	})
+	urls := make([]string, 0, len(durl))
+	for _, d := range durl {
+		urls = append(urls, d.URL)
+	}
+	return urls
+}
+
 func (client *Client) GetVideoInfo(id string) (*VideoInfoResp, error) {
 	url := fmt.Sprintf("%s?bvid=%s", videoInfoUrl, id)
 	client.HttpClient = &http.Client{}
@@ -295,16 +348,88 @@ func (client *Client) GetVideoInfo(id string) (*VideoInfoResp, error) {
 type Fnval int64
 
 const (
-	FnvalMP4  Fnval = 1
-	FnvalDash Fnval = 16
-	FnvalHDR  Fnval = 64
-	Fnval4K   Fnval = 128
+	FnvalFLV         Fnval = 0
+	FnvalMP4         Fnval = 1
+	FnvalDash        Fnval = 16
+	FnvalHDR         Fnval = 64
+	Fnval4K          Fnval = 128
+	FnvalDolbyAudio  Fnval = 256
+	FnvalDolbyVision Fnval = 512
+	Fnval8K          Fnval = 1024
+	FnvalAV1         Fnval = 2048
+
+	// FnvalDashAll requests every dash stream the server is able to offer.
+	FnvalDashAll = FnvalDash | FnvalHDR | Fnval4K | FnvalDolbyAudio | FnvalDolbyVision | Fnval8K | FnvalAV1
 
 	FnvalAudio64K  Fnval = 30216
 	FnvalAudio132K Fnval = 30232
 	FnvalAudio192K Fnval = 30280
 )
 
+var fnvalNames = []struct {
+	name  string
+	fnval Fnval
+}{
+	{"mp4", FnvalMP4},
+	{"dash", FnvalDash},
+	{"hdr", FnvalHDR},
+	{"4k", Fnval4K},
+	{"dolby", FnvalDolbyAudio},
+	{"dolbyvision", FnvalDolbyVision},
+	{"8k", Fnval8K},
+	{"av1", FnvalAV1},
+}
+
+// ComposeFnval combines the given flags into one fnval bitmask.
+func ComposeFnval(flags ...Fnval) Fnval {
+	var fnval Fnval
+	for _, flag := range flags {
+		fnval |= flag
+	}
+	return fnval
+}
+
+// ParseFnval parses a bitmask written as flag names joined by '|', e.g. "dash|hdr|4k".
+func ParseFnval(s string) (Fnval, error) {
+	var fnval Fnval
+	for _, name := range strings.Split(s, "|") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if len(name) == 0 {
+			continue
+		}
+		found := false
+		for _, n := range fnvalNames {
+			if n.name == name {
+				fnval |= n.fnval
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown fnval flag: %s", name)
+		}
+	}
+	return fnval, nil
+}
+
+// Has reports whether all bits of flag are set.
+func (fnval Fnval) Has(flag Fnval) bool {
+	return fnval&flag == flag
+}
+
+func (fnval Fnval) String() string {
+	if fnval == FnvalFLV {
+		return "flv"
+	}
+	names := make([]string, 0, len(fnvalNames))
+	for _, n := range fnvalNames {
+		if fnval.Has(n.fnval) {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
 type Qn int64
 
 func (qn Qn) String() string {
@@ -321,12 +446,20 @@ func (qn Qn) String() string {
 		return "720P60"
 	case Qn1080P:
 		return "1080P"
+	case QnAIRepair:
+		return "AI Repair"
 	case Qn1080PPlus:
 		return "1080P+"
 	case Qn1080P60:
 		return "1080P60"
 	case Qn4k:
 		return "4K"
+	case QnHDR:
+		return "HDR"
+	case QnDolbyVision:
+		return "Dolby Vision"
+	case Qn8K:
+		return "8K"
 	case QnAudio64K:
 		return "64K"
 	case QnAudio132K:
@@ -342,16 +475,42 @@ func (qn Qn) String() string {
 	}
 }
 
+// audioQnRanks orders the audio qualities, whose ids do not follow the quality.
+var audioQnRanks = map[Qn]int{
+	QnAudio64K:   1,
+	QnAudio132K:  2,
+	QnAudio192K:  3,
+	QnAudioDolby: 4,
+	QnAudioHiRes: 5,
+}
+
+// Less reports whether qn is of lower quality than other.
+func (qn Qn) Less(other Qn) bool {
+	if qn.IsAudio() && other.IsAudio() {
+		return audioQnRanks[qn] < audioQnRanks[other]
+	}
+	return qn < other
+}
+
+// IsAudio reports whether qn identifies an audio stream.
+func (qn Qn) IsAudio() bool {
+	return qn > 2048
+}
+
 const (
-	Qn240P      Qn = 6
-	Qn360P      Qn = 16
-	Qn480P      Qn = 32
-	Qn720P      Qn = 64
-	Qn720P60    Qn = 74
-	Qn1080P     Qn = 80
-	Qn1080PPlus Qn = 112
-	Qn1080P60   Qn = 116
-	Qn4k        Qn = 120
+	Qn240P        Qn = 6
+	Qn360P        Qn = 16
+	Qn480P        Qn = 32
+	Qn720P        Qn = 64
+	Qn720P60      Qn = 74
+	Qn1080P       Qn = 80
+	QnAIRepair    Qn = 100
+	Qn1080PPlus   Qn = 112
+	Qn1080P60     Qn = 116
+	Qn4k          Qn = 120
+	QnHDR         Qn = 125
+	QnDolbyVision Qn = 126
+	Qn8K          Qn = 127
 
 	QnAudio64K   Qn = 30216
 	QnAudio132K  Qn = 30232
@@ -360,6 +519,20 @@ const (
 	QnAudioHiRes Qn = 30251
 )
 
+// QnDescription returns the label the server gives to qn in support_formats,
+// falling back to Qn.String when the server does not describe it.
+func (playUrlResp *PlayUrlResp) QnDescription(qn Qn) string {
+	for _, format := range playUrlResp.Data.SupportFormats {
+		if Qn(format.Quality) == qn && len(format.NewDescription) != 0 {
+			return format.NewDescription
+		}
+	}
+	if s := qn.String(); len(s) != 0 {
+		return s
+	}
+	return fmt.Sprintf("%d", qn)
+}
+
 func (client *Client) PlayUrl(bvid string, cid int64, qn Qn, fnval Fnval) (*PlayUrlResp, error) {
 	id, err := video.ExtractBvID(bvid)
 	if err != nil {
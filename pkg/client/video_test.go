@@ -1,6 +1,7 @@
 package client
 
 import (
+	"encoding/json"
 	"os"
 	"testing"
 
@@ -49,3 +50,37 @@ func TestClient_PlayUrl(t *testing.T) {
 	}
 	t.Log(util.MustMarshalIndent(resp))
 }
+
+func TestParseFnval(t *testing.T) {
+	fnval, err := ParseFnval("dash|hdr|4k|dolby|8k|av1")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if fnval != ComposeFnval(FnvalDash, FnvalHDR, Fnval4K, FnvalDolbyAudio, Fnval8K, FnvalAV1) {
+		t.Errorf("unexpected fnval %d", fnval)
+	}
+	if !FnvalDashAll.Has(fnval) {
+		t.Errorf("%s is not included in %s", fnval, FnvalDashAll)
+	}
+	if _, err = ParseFnval("dash|unknown"); err == nil {
+		t.Error("expected error for unknown flag")
+	}
+}
+
+func TestPlayUrlResp_AudioStreams(t *testing.T) {
+	body := `{"data":{"dash":{"audio":[{"id":30280,"codecs":"mp4a.40.2"}],"dolby":{"type":2,"audio":[{"id":30250,"codecs":"ec-3"}]},"flac":{"display":true,"audio":{"id":30251,"codecs":"fLaC"}}}}}`
+	resp := &PlayUrlResp{}
+	if err := json.Unmarshal([]byte(body), resp); err != nil {
+		t.Error(err)
+		return
+	}
+	audios := resp.AudioStreams()
+	if len(audios) != 3 {
+		t.Errorf("expected 3 audio streams, got %d", len(audios))
+		return
+	}
+	if !Qn(audios[0].ID).Less(Qn(audios[1].ID)) || !Qn(audios[1].ID).Less(Qn(audios[2].ID)) {
+		t.Errorf("unexpected audio order %s", util.MustMarshal(audios))
+	}
+}
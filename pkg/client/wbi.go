@@ -0,0 +1,100 @@
+package client
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/misssonder/bilibili/pkg/errors"
+)
+
+// mixinKeyEncTab shuffles the img and sub keys into the mixin key.
+var mixinKeyEncTab = [...]int{
+	46, 47, 18, 2, 53, 8, 23, 32, 15, 50, 10, 31, 58, 3, 45, 35, 27, 43, 5, 49,
+	33, 9, 42, 19, 29, 28, 14, 39, 12, 38, 41, 13, 37, 48, 7, 16, 24, 55, 40,
+	61, 26, 17, 0, 1, 60, 51, 30, 4, 22, 25, 54, 21, 56, 59, 6, 63, 57, 62, 11,
+	36, 20, 34, 44, 52,
+}
+
+// wbiMixinKey https://github.com/SocialSisterYi/bilibili-API-collect/blob/master/docs/misc/sign/wbi.md
+func wbiMixinKey(imgKey, subKey string) string {
+	raw := imgKey + subKey
+	key := make([]byte, 0, 32)
+	for _, i := range mixinKeyEncTab {
+		if i < len(raw) {
+			key = append(key, raw[i])
+		}
+		if len(key) == 32 {
+			break
+		}
+	}
+	return string(key)
+}
+
+// signWbi encodes the query with wts and the w_rid signature of it.
+func signWbi(params url.Values, mixinKey string, now time.Time) string {
+	signed := url.Values{}
+	for key, values := range params {
+		for _, value := range values {
+			signed.Add(key, strings.Map(func(r rune) rune {
+				if strings.ContainsRune("!'()*", r) {
+					return -1
+				}
+				return r
+			}, value))
+		}
+	}
+	signed.Set("wts", strconv.FormatInt(now.Unix(), 10))
+	query := strings.ReplaceAll(signed.Encode(), "+", "%20")
+	sum := md5.Sum([]byte(query + mixinKey))
+	return query + "&w_rid=" + hex.EncodeToString(sum[:])
+}
+
+// wbiKey gets the mixin key of today, the nav info carries the keys without the login as well.
+// The chat reconnects call it alongside the recording, hence the local http client.
+func (client *Client) wbiKey() (string, error) {
+	httpClient := &http.Client{}
+	request, err := client.newCookieRequest(http.MethodGet, navInfoUrl, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	navInfoResp := &NavInfoResp{}
+	if err = json.Unmarshal(body, navInfoResp); err != nil {
+		return "", err
+	}
+	imgKey := wbiImageKey(navInfoResp.Data.WbiImg.ImgURL)
+	subKey := wbiImageKey(navInfoResp.Data.WbiImg.SubURL)
+	if len(imgKey) == 0 || len(subKey) == 0 {
+		return "", errors.StatusError{Code: navInfoResp.Code, Cause: "no wbi keys: " + navInfoResp.Message}
+	}
+	return wbiMixinKey(imgKey, subKey), nil
+}
+
+// wbiImageKey is the file name of the key image like https://i0.hdslb.com/bfs/wbi/7cd084941338484aae1ad9425b84077c.png.
+func wbiImageKey(imageUrl string) string {
+	name := path.Base(imageUrl)
+	if name == "." || name == "/" {
+		return ""
+	}
+	return strings.TrimSuffix(name, path.Ext(name))
+}
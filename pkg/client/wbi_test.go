@@ -0,0 +1,17 @@
+package client
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignWbi(t *testing.T) {
+	key := wbiMixinKey(wbiImageKey("https://i0.hdslb.com/bfs/wbi/7cd084941338484aae1ad9425b84077c.png"),
+		wbiImageKey("https://i0.hdslb.com/bfs/wbi/4932caff0ff746eab6f01bf08b70ac45.png"))
+	assert.Equal(t, "ea1db124af3c7062474693fa704f4ff8", key)
+	query := signWbi(url.Values{"foo": {"114"}, "bar": {"514"}, "zab": {"1919810"}}, key, time.Unix(1702204169, 0))
+	assert.Equal(t, "bar=514&foo=114&wts=1702204169&zab=1919810&w_rid=8f6f2b5b3d485fe1886cec6a0be8c5d4", query)
+}
@@ -0,0 +1,259 @@
+package danmaku
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// ASSOptions are how the danmaku are laid out on the screen.
+type ASSOptions struct {
+	Width    int
+	Height   int
+	FontName string
+	// FontSize is the size of a standard danmaku, the others are scaled by it.
+	FontSize int
+	// Opacity is from 0 to 1.
+	Opacity float64
+	// ScrollDuration is how long a scrolling danmaku crosses the screen.
+	ScrollDuration time.Duration
+	// FixedDuration is how long a top or bottom danmaku stays.
+	FixedDuration time.Duration
+	// Area is the part of the screen height the scrolling danmaku take, the lower it is the fewer are shown.
+	Area float64
+	// Overlap puts the danmaku which finds no free lane on the least busy one instead of dropping it.
+	Overlap bool
+}
+
+// DefaultASSOptions are for a 1080p video.
+var DefaultASSOptions = ASSOptions{
+	Width:          1920,
+	Height:         1080,
+	FontName:       "sans-serif",
+	FontSize:       50,
+	Opacity:        0.8,
+	ScrollDuration: 8 * time.Second,
+	FixedDuration:  4 * time.Second,
+	Area:           1,
+}
+
+// lane is a row of the screen, it keeps the last danmaku put on it.
+type lane struct {
+	start time.Duration
+	end   time.Duration
+	width float64
+	used  bool
+}
+
+// layout places the danmaku into lanes so they do not cover each other.
+type layout struct {
+	options ASSOptions
+	height  int
+	scroll  []lane
+	top     []lane
+	bottom  []lane
+}
+
+func newLayout(options ASSOptions) *layout {
+	height := options.FontSize + options.FontSize/5
+	if height <= 0 {
+		height = 1
+	}
+	count := options.Height / height
+	scroll := int(float64(count) * options.Area)
+	if scroll < 1 {
+		scroll = 1
+	}
+	return &layout{
+		options: options,
+		height:  height,
+		scroll:  make([]lane, scroll),
+		top:     make([]lane, count),
+		bottom:  make([]lane, count),
+	}
+}
+
+// speed is how many pixels per second a danmaku of the width scrolls.
+func (l *layout) speed(width float64) float64 {
+	return (float64(l.options.Width) + width) / l.options.ScrollDuration.Seconds()
+}
+
+// scrollFree reports whether a danmaku of width starting at t neither touches the tail of the last one on the lane
+// nor catches it up before it leaves the screen.
+func (l *layout) scrollFree(last lane, t time.Duration, width float64) bool {
+	if !last.used {
+		return true
+	}
+	elapsed := (t - last.start).Seconds()
+	if elapsed*l.speed(last.width) < last.width {
+		return false
+	}
+	// where the head of the new one is when the tail of the last one leaves the screen
+	remaining := (last.end - t).Seconds()
+	return float64(l.options.Width)-remaining*l.speed(width) >= 0
+}
+
+// place returns the lane of the danmaku, false when it is dropped.
+func (l *layout) place(d Danmaku, width float64) (int, bool) {
+	scroll := d.Mode.IsScroll() || d.Mode == ModeReverse
+	lanes, duration := l.top, l.options.FixedDuration
+	if scroll {
+		lanes, duration = l.scroll, l.options.ScrollDuration
+	} else if d.Mode == ModeBottom {
+		lanes = l.bottom
+	}
+	best := -1
+	for i, last := range lanes {
+		free := !last.used || last.end <= d.Progress
+		if scroll {
+			free = l.scrollFree(last, d.Progress, width)
+		}
+		if free {
+			best = i
+			break
+		}
+	}
+	if best < 0 {
+		if !l.options.Overlap || len(lanes) == 0 {
+			return 0, false
+		}
+		best = 0
+		for i, last := range lanes {
+			if last.end < lanes[best].end {
+				best = i
+			}
+		}
+	}
+	lanes[best] = lane{start: d.Progress, end: d.Progress + duration, width: width, used: true}
+	return best, true
+}
+
+// textWidth estimates the width of the text in pixels, a wide rune is as wide as the font size and others half of it.
+func textWidth(text string, fontSize int) float64 {
+	width := 0.0
+	for _, line := range strings.Split(text, "\n") {
+		lineWidth := 0.0
+		for _, r := range line {
+			if r < 0x80 {
+				lineWidth += 0.5
+			} else {
+				lineWidth++
+			}
+		}
+		if lineWidth > width {
+			width = lineWidth
+		}
+	}
+	return width * float64(fontSize)
+}
+
+var assEscaper = strings.NewReplacer("\r\n", `\N`, "\n", `\N`, `/n`, `\N`, "{", "｛", "}", "｝")
+
+// WriteASS writes the danmaku as ASS subtitles, the advanced, code and BAS danmaku are skipped,
+// so are those which find no free lane unless options.Overlap is set.
+func WriteASS(w io.Writer, danmaku []Danmaku, options ASSOptions) error {
+	if options.Width <= 0 || options.Height <= 0 {
+		options.Width, options.Height = DefaultASSOptions.Width, DefaultASSOptions.Height
+	}
+	if options.FontSize <= 0 {
+		// scaled from 50 of 1080p
+		options.FontSize = DefaultASSOptions.FontSize * options.Height / DefaultASSOptions.Height
+	}
+	if len(options.FontName) == 0 {
+		options.FontName = DefaultASSOptions.FontName
+	}
+	if options.ScrollDuration <= 0 {
+		options.ScrollDuration = DefaultASSOptions.ScrollDuration
+	}
+	if options.FixedDuration <= 0 {
+		options.FixedDuration = DefaultASSOptions.FixedDuration
+	}
+	if options.Area <= 0 || options.Area > 1 {
+		options.Area = 1
+	}
+	if options.Opacity <= 0 || options.Opacity > 1 {
+		options.Opacity = 1
+	}
+
+	sorted := make([]Danmaku, len(danmaku))
+	copy(sorted, danmaku)
+	Sort(sorted)
+
+	bw := bufio.NewWriter(w)
+	alpha := int(math.Round((1 - options.Opacity) * 255))
+	fmt.Fprintf(bw, `[Script Info]
+ScriptType: v4.00+
+PlayResX: %d
+PlayResY: %d
+WrapStyle: 2
+ScaledBorderAndShadow: yes
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Danmaku,%s,%d,&H%02XFFFFFF,&H%02XFFFFFF,&H%02X000000,&H%02X000000,1,0,0,0,100,100,0,0,1,1,0,7,0,0,0,1
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+`, options.Width, options.Height, options.FontName, options.FontSize, alpha, alpha, alpha, alpha)
+
+	l := newLayout(options)
+	for _, d := range sorted {
+		if !d.Mode.IsScroll() && d.Mode != ModeReverse && d.Mode != ModeTop && d.Mode != ModeBottom {
+			continue
+		}
+		fontSize := options.FontSize
+		if d.FontSize > 0 && d.FontSize != FontSizeStandard {
+			fontSize = options.FontSize * d.FontSize / FontSizeStandard
+		}
+		width := textWidth(d.Content, fontSize)
+		i, ok := l.place(d, width)
+		if !ok {
+			continue
+		}
+		var (
+			tags string
+			end  time.Duration
+		)
+		switch {
+		case d.Mode.IsScroll():
+			y := i * l.height
+			tags = fmt.Sprintf(`\move(%d,%d,%d,%d)`, options.Width, y, -int(width), y)
+			end = d.Progress + options.ScrollDuration
+		case d.Mode == ModeReverse:
+			y := i * l.height
+			tags = fmt.Sprintf(`\move(%d,%d,%d,%d)`, -int(width), y, options.Width, y)
+			end = d.Progress + options.ScrollDuration
+		case d.Mode == ModeTop:
+			tags = fmt.Sprintf(`\an8\pos(%d,%d)`, options.Width/2, i*l.height)
+			end = d.Progress + options.FixedDuration
+		default:
+			tags = fmt.Sprintf(`\an2\pos(%d,%d)`, options.Width/2, options.Height-i*l.height)
+			end = d.Progress + options.FixedDuration
+		}
+		if fontSize != options.FontSize {
+			tags += fmt.Sprintf(`\fs%d`, fontSize)
+		}
+		if color := d.Color & 0xFFFFFF; color != 0xFFFFFF {
+			// ASS colours are BGR
+			tags += fmt.Sprintf(`\c&H%02X%02X%02X&`, color&0xFF, color>>8&0xFF, color>>16)
+			if color == 0 {
+				tags += `\3c&HFFFFFF&`
+			}
+		}
+		fmt.Fprintf(bw, "Dialogue: 0,%s,%s,Danmaku,,0,0,0,,{%s}%s\n",
+			assTimestamp(d.Progress), assTimestamp(end), tags, assEscaper.Replace(d.Content))
+	}
+	return bw.Flush()
+}
+
+// assTimestamp formats d as h:mm:ss.cc.
+func assTimestamp(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	cs := d.Milliseconds() / 10
+	return fmt.Sprintf("%d:%02d:%02d.%02d", cs/360000, cs/6000%60, cs/100%60, cs%100)
+}
@@ -0,0 +1,171 @@
+package danmaku
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mode is how the danmaku moves on the screen.
+type Mode int
+
+const (
+	ModeScroll   Mode = 1
+	ModeScroll2  Mode = 2
+	ModeScroll3  Mode = 3
+	ModeBottom   Mode = 4
+	ModeTop      Mode = 5
+	ModeReverse  Mode = 6
+	ModeAdvanced Mode = 7
+	ModeCode     Mode = 8
+	ModeBAS      Mode = 9
+)
+
+// IsScroll reports whether the danmaku scrolls from the right to the left.
+func (mode Mode) IsScroll() bool {
+	return mode == ModeScroll || mode == ModeScroll2 || mode == ModeScroll3
+}
+
+// Pool is the pool the danmaku is sent to.
+type Pool int
+
+const (
+	PoolNormal   Pool = 0
+	PoolSubtitle Pool = 1
+	PoolSpecial  Pool = 2
+)
+
+// FontSizeStandard is the font size of most danmaku, 18 is small and 36 is large.
+const FontSizeStandard = 25
+
+// Danmaku is a bullet comment shown at Progress of the video.
+type Danmaku struct {
+	ID       int64
+	Progress time.Duration
+	Mode     Mode
+	FontSize int
+	// Color is 0xRRGGBB.
+	Color uint32
+	// MidHash is the crc32 of the sender mid.
+	MidHash string
+	Content string
+	// Ctime is when the danmaku is sent.
+	Ctime time.Time
+	// Weight is the shield level, 0 to 10.
+	Weight int
+	Pool   Pool
+	Attr   int
+}
+
+// Sort sorts the danmaku by their progress.
+func Sort(danmaku []Danmaku) {
+	sort.SliceStable(danmaku, func(i, j int) bool {
+		return danmaku[i].Progress < danmaku[j].Progress
+	})
+}
+
+// xmlList is the XML danmaku list of comment.bilibili.com.
+type xmlList struct {
+	XMLName    xml.Name  `xml:"i"`
+	ChatServer string    `xml:"chatserver"`
+	ChatID     int64     `xml:"chatid"`
+	Mission    int       `xml:"mission"`
+	MaxLimit   int       `xml:"maxlimit"`
+	State      int       `xml:"state"`
+	RealName   int       `xml:"real_name"`
+	Source     string    `xml:"source"`
+	Danmaku    []xmlItem `xml:"d"`
+}
+
+type xmlItem struct {
+	P       string `xml:"p,attr"`
+	Content string `xml:",chardata"`
+}
+
+// ParseXML parses the XML danmaku list, the items which can not be parsed are skipped.
+func ParseXML(r io.Reader) ([]Danmaku, error) {
+	list := xmlList{}
+	if err := xml.NewDecoder(r).Decode(&list); err != nil {
+		return nil, err
+	}
+	danmaku := make([]Danmaku, 0, len(list.Danmaku))
+	for _, item := range list.Danmaku {
+		d, err := parseP(item.P)
+		if err != nil {
+			continue
+		}
+		d.Content = item.Content
+		danmaku = append(danmaku, d)
+	}
+	return danmaku, nil
+}
+
+// parseP parses the p attribute: progress,mode,font size,color,ctime,pool,mid hash,id[,weight].
+func parseP(p string) (Danmaku, error) {
+	fields := strings.Split(p, ",")
+	if len(fields) < 8 {
+		return Danmaku{}, fmt.Errorf("invalid danmaku attribute: %s", p)
+	}
+	progress, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return Danmaku{}, err
+	}
+	ints := make([]int64, 0, 4)
+	for _, i := range []int{1, 2, 3, 4, 5} {
+		v, err := strconv.ParseInt(fields[i], 10, 64)
+		if err != nil {
+			return Danmaku{}, err
+		}
+		ints = append(ints, v)
+	}
+	id, err := strconv.ParseInt(fields[7], 10, 64)
+	if err != nil {
+		return Danmaku{}, err
+	}
+	d := Danmaku{
+		ID:       id,
+		Progress: time.Duration(progress * float64(time.Second)),
+		Mode:     Mode(ints[0]),
+		FontSize: int(ints[1]),
+		Color:    uint32(ints[2]),
+		Ctime:    time.Unix(ints[3], 0),
+		Pool:     Pool(ints[4]),
+		MidHash:  fields[6],
+	}
+	if len(fields) > 8 {
+		d.Weight, _ = strconv.Atoi(fields[8])
+	}
+	return d, nil
+}
+
+// WriteXML writes the danmaku of cid in the XML list format.
+func WriteXML(w io.Writer, cid int64, danmaku []Danmaku) error {
+	list := xmlList{
+		ChatServer: "chat.bilibili.com",
+		ChatID:     cid,
+		MaxLimit:   len(danmaku),
+		Source:     "k-v",
+		Danmaku:    make([]xmlItem, 0, len(danmaku)),
+	}
+	for _, d := range danmaku {
+		list.Danmaku = append(list.Danmaku, xmlItem{
+			P: fmt.Sprintf("%s,%d,%d,%d,%d,%d,%s,%d,%d",
+				strconv.FormatFloat(d.Progress.Seconds(), 'f', 5, 64),
+				d.Mode, d.FontSize, d.Color, d.Ctime.Unix(), d.Pool, d.MidHash, d.ID, d.Weight),
+			Content: d.Content,
+		})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	if err := encoder.Encode(list); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,100 @@
+package danmaku
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const list = `<?xml version="1.0" encoding="UTF-8"?><i><chatserver>chat.bilibili.com</chatserver><chatid>123</chatid><mission>0</mission><maxlimit>3000</maxlimit><state>0</state><real_name>0</real_name><source>k-v</source>` +
+	`<d p="12.34500,1,25,16777215,1700000000,0,5a2b3c4d,1234567890123456789,10">前方高能</d>` +
+	`<d p="3.00000,5,36,16711680,1700000001,1,deadbeef,42">&lt;top&gt;</d>` +
+	`<d p="bad">skipped</d></i>`
+
+func TestParseXML(t *testing.T) {
+	danmaku, err := ParseXML(strings.NewReader(list))
+	assert.NoError(t, err)
+	assert.Equal(t, []Danmaku{
+		{
+			ID:       1234567890123456789,
+			Progress: 12345 * time.Millisecond,
+			Mode:     ModeScroll,
+			FontSize: 25,
+			Color:    0xFFFFFF,
+			MidHash:  "5a2b3c4d",
+			Content:  "前方高能",
+			Ctime:    time.Unix(1700000000, 0),
+			Weight:   10,
+			Pool:     PoolNormal,
+		},
+		{
+			ID:       42,
+			Progress: 3 * time.Second,
+			Mode:     ModeTop,
+			FontSize: 36,
+			Color:    0xFF0000,
+			MidHash:  "deadbeef",
+			Content:  "<top>",
+			Ctime:    time.Unix(1700000001, 0),
+			Pool:     PoolSubtitle,
+		},
+	}, danmaku)
+}
+
+func TestWriteXML(t *testing.T) {
+	danmaku, err := ParseXML(strings.NewReader(list))
+	assert.NoError(t, err)
+	var buf bytes.Buffer
+	assert.NoError(t, WriteXML(&buf, 123, danmaku))
+	assert.Contains(t, buf.String(), `<d p="3.00000,5,36,16711680,1700000001,1,deadbeef,42,0">&lt;top&gt;</d>`)
+	parsed, err := ParseXML(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, danmaku, parsed)
+}
+
+func TestWriteASS(t *testing.T) {
+	danmaku := []Danmaku{
+		{Progress: 5 * time.Second, Mode: ModeScroll, FontSize: 25, Color: 0xFFFFFF, Content: "abcd"},
+		{Progress: 0, Mode: ModeScroll, FontSize: 25, Color: 0xFFFFFF, Content: "abcd"},
+		{Progress: 0, Mode: ModeScroll, FontSize: 25, Color: 0xFFFFFF, Content: "abcd"},
+		{Progress: time.Second, Mode: ModeTop, FontSize: 25, Color: 0xFF0000, Content: "{红}"},
+		{Progress: time.Second, Mode: ModeBottom, FontSize: 36, Color: 0xFFFFFF, Content: "大"},
+		{Progress: time.Second, Mode: ModeAdvanced, FontSize: 25, Color: 0xFFFFFF, Content: "[0,0]"},
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, WriteASS(&buf, danmaku, DefaultASSOptions))
+	ass := buf.String()
+	assert.Contains(t, ass, "PlayResX: 1920\nPlayResY: 1080\n")
+	assert.Contains(t, ass, "Style: Danmaku,sans-serif,50,&H33FFFFFF,")
+	assert.Equal(t, strings.Join([]string{
+		`Dialogue: 0,0:00:00.00,0:00:08.00,Danmaku,,0,0,0,,{\move(1920,0,-100,0)}abcd`,
+		`Dialogue: 0,0:00:00.00,0:00:08.00,Danmaku,,0,0,0,,{\move(1920,60,-100,60)}abcd`,
+		`Dialogue: 0,0:00:01.00,0:00:05.00,Danmaku,,0,0,0,,{\an8\pos(960,0)\c&H0000FF&}｛红｝`,
+		`Dialogue: 0,0:00:01.00,0:00:05.00,Danmaku,,0,0,0,,{\an2\pos(960,1080)\fs72}大`,
+		// the tail of the first one has entered and it leaves before this one catches it up
+		`Dialogue: 0,0:00:05.00,0:00:13.00,Danmaku,,0,0,0,,{\move(1920,0,-100,0)}abcd`,
+		"",
+	}, "\n"), ass[strings.Index(ass, "Dialogue:"):])
+}
+
+func TestWriteASSDensity(t *testing.T) {
+	danmaku := []Danmaku{
+		{Progress: 0, Mode: ModeScroll, FontSize: 25, Content: "abcd"},
+		{Progress: 0, Mode: ModeScroll, FontSize: 25, Content: "abcd"},
+		// the first one is still entering the screen
+		{Progress: 100 * time.Millisecond, Mode: ModeScroll, FontSize: 25, Content: "abcd"},
+	}
+	options := DefaultASSOptions
+	options.Area = 0.01
+	var buf bytes.Buffer
+	assert.NoError(t, WriteASS(&buf, danmaku, options))
+	assert.Equal(t, 1, strings.Count(buf.String(), "Dialogue:"))
+
+	options.Overlap = true
+	buf.Reset()
+	assert.NoError(t, WriteASS(&buf, danmaku, options))
+	assert.Equal(t, 3, strings.Count(buf.String(), "Dialogue:"))
+}
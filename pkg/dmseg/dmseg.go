@@ -0,0 +1,176 @@
+package dmseg
+
+import (
+	"time"
+
+	"github.com/misssonder/bilibili/pkg/danmaku"
+)
+
+// SegmentDuration is the length of the video a segment holds the danmaku of.
+const SegmentDuration = 6 * time.Minute
+
+// Segments is how many segments hold the danmaku of a video of the duration.
+func Segments(duration time.Duration) int {
+	if duration <= 0 {
+		return 1
+	}
+	return int((duration + SegmentDuration - 1) / SegmentDuration)
+}
+
+// DanmakuElem is a danmaku of the segment, Progress is in milliseconds and Ctime is a unix timestamp.
+type DanmakuElem struct {
+	ID        int64
+	Progress  int32
+	Mode      int32
+	FontSize  int32
+	Color     uint32
+	MidHash   string
+	Content   string
+	Ctime     int64
+	Weight    int32
+	Action    string
+	Pool      int32
+	IDStr     string
+	Attr      int32
+	Animation string
+}
+
+// DmSegMobileReply is the reply of the segmented danmaku API.
+type DmSegMobileReply struct {
+	Elems []DanmakuElem
+	// State is 1 when the danmaku of the video are closed.
+	State int32
+}
+
+// Unmarshal decodes the protobuf wire format of DmSegMobileReply, the unknown fields are skipped.
+func Unmarshal(data []byte) (*DmSegMobileReply, error) {
+	reply := &DmSegMobileReply{}
+	d := &decoder{data: data}
+	for !d.done() {
+		field, wireType, err := d.key()
+		if err != nil {
+			return nil, err
+		}
+		switch field {
+		case 1:
+			if err = expect(field, wireType, wireBytes); err != nil {
+				return nil, err
+			}
+			b, err := d.bytes()
+			if err != nil {
+				return nil, err
+			}
+			elem, err := unmarshalElem(b)
+			if err != nil {
+				return nil, err
+			}
+			reply.Elems = append(reply.Elems, elem)
+		case 2:
+			if err = expect(field, wireType, wireVarint); err != nil {
+				return nil, err
+			}
+			v, err := d.varint()
+			if err != nil {
+				return nil, err
+			}
+			reply.State = int32(v)
+		default:
+			if err = d.skip(wireType); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return reply, nil
+}
+
+func unmarshalElem(data []byte) (DanmakuElem, error) {
+	elem := DanmakuElem{}
+	d := &decoder{data: data}
+	for !d.done() {
+		field, wireType, err := d.key()
+		if err != nil {
+			return elem, err
+		}
+		var str *string
+		switch field {
+		case 6:
+			str = &elem.MidHash
+		case 7:
+			str = &elem.Content
+		case 10:
+			str = &elem.Action
+		case 12:
+			str = &elem.IDStr
+		case 14:
+			str = &elem.Animation
+		}
+		if str != nil {
+			if err = expect(field, wireType, wireBytes); err != nil {
+				return elem, err
+			}
+			b, err := d.bytes()
+			if err != nil {
+				return elem, err
+			}
+			*str = string(b)
+			continue
+		}
+		if field > 14 || wireType != wireVarint {
+			if err = d.skip(wireType); err != nil {
+				return elem, err
+			}
+			continue
+		}
+		v, err := d.varint()
+		if err != nil {
+			return elem, err
+		}
+		switch field {
+		case 1:
+			elem.ID = int64(v)
+		case 2:
+			elem.Progress = int32(v)
+		case 3:
+			elem.Mode = int32(v)
+		case 4:
+			elem.FontSize = int32(v)
+		case 5:
+			elem.Color = uint32(v)
+		case 8:
+			elem.Ctime = int64(v)
+		case 9:
+			elem.Weight = int32(v)
+		case 11:
+			elem.Pool = int32(v)
+		case 13:
+			elem.Attr = int32(v)
+		}
+	}
+	return elem, nil
+}
+
+// Danmaku converts the elem to the danmaku model.
+func (elem DanmakuElem) Danmaku() danmaku.Danmaku {
+	return danmaku.Danmaku{
+		ID:       elem.ID,
+		Progress: time.Duration(elem.Progress) * time.Millisecond,
+		Mode:     danmaku.Mode(elem.Mode),
+		FontSize: int(elem.FontSize),
+		Color:    elem.Color,
+		MidHash:  elem.MidHash,
+		Content:  elem.Content,
+		Ctime:    time.Unix(elem.Ctime, 0),
+		Weight:   int(elem.Weight),
+		Pool:     danmaku.Pool(elem.Pool),
+		Attr:     int(elem.Attr),
+	}
+}
+
+// Danmaku converts the elems of the reply to the danmaku model.
+func (reply *DmSegMobileReply) Danmaku() []danmaku.Danmaku {
+	list := make([]danmaku.Danmaku, 0, len(reply.Elems))
+	for _, elem := range reply.Elems {
+		list = append(list, elem.Danmaku())
+	}
+	return list
+}
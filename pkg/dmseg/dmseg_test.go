@@ -0,0 +1,115 @@
+package dmseg
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/misssonder/bilibili/pkg/danmaku"
+	"github.com/stretchr/testify/assert"
+)
+
+func uvarint(v uint64) []byte {
+	b := make([]byte, binary.MaxVarintLen64)
+	return b[:binary.PutUvarint(b, v)]
+}
+
+func varintField(field int, v uint64) []byte {
+	return concat(uvarint(uint64(field<<3|wireVarint)), uvarint(v))
+}
+
+func bytesField(field int, v []byte) []byte {
+	return concat(uvarint(uint64(field<<3|wireBytes)), uvarint(uint64(len(v))), v)
+}
+
+func concat(fields ...[]byte) []byte {
+	b := make([]byte, 0)
+	for _, field := range fields {
+		b = append(b, field...)
+	}
+	return b
+}
+
+func TestUnmarshal(t *testing.T) {
+	elem := concat(
+		varintField(1, 1234567890123456789),
+		varintField(2, 372500),
+		varintField(3, 5),
+		varintField(4, 25),
+		varintField(5, 0xFF0000),
+		bytesField(6, []byte("5a2b3c4d")),
+		bytesField(7, []byte("前方高能")),
+		varintField(8, 1700000000),
+		varintField(9, 10),
+		// an unknown fixed32 field is skipped
+		[]byte{byte(15<<3 | wireFixed32), 1, 2, 3, 4},
+		varintField(11, 1),
+		bytesField(12, []byte("1234567890123456789")),
+		varintField(13, 4),
+	)
+	data := concat(
+		bytesField(1, elem),
+		bytesField(1, concat(varintField(2, 1000), bytesField(7, []byte("2")))),
+		varintField(2, 0),
+		// ai_flag is not decoded
+		bytesField(3, []byte{8, 1}),
+	)
+	reply, err := Unmarshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, &DmSegMobileReply{Elems: []DanmakuElem{
+		{
+			ID:       1234567890123456789,
+			Progress: 372500,
+			Mode:     5,
+			FontSize: 25,
+			Color:    0xFF0000,
+			MidHash:  "5a2b3c4d",
+			Content:  "前方高能",
+			Ctime:    1700000000,
+			Weight:   10,
+			Pool:     1,
+			IDStr:    "1234567890123456789",
+			Attr:     4,
+		},
+		{Progress: 1000, Content: "2"},
+	}}, reply)
+
+	assert.Equal(t, danmaku.Danmaku{
+		ID:       1234567890123456789,
+		Progress: 6*time.Minute + 12500*time.Millisecond,
+		Mode:     danmaku.ModeTop,
+		FontSize: 25,
+		Color:    0xFF0000,
+		MidHash:  "5a2b3c4d",
+		Content:  "前方高能",
+		Ctime:    time.Unix(1700000000, 0),
+		Weight:   10,
+		Pool:     danmaku.PoolSubtitle,
+		Attr:     4,
+	}, reply.Danmaku()[0])
+}
+
+func TestUnmarshalEmpty(t *testing.T) {
+	reply, err := Unmarshal(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, reply.Elems)
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+	elem := bytesField(7, []byte("content"))
+	_, err := Unmarshal(bytesField(1, elem)[:len(elem)])
+	assert.ErrorIs(t, err, errTruncated)
+	// a string where the elems are
+	_, err = Unmarshal(varintField(1, 1))
+	assert.Error(t, err)
+	// groups are not supported
+	_, err = Unmarshal([]byte{byte(5<<3 | 3)})
+	assert.Error(t, err)
+}
+
+func TestSegments(t *testing.T) {
+	assert.Equal(t, 1, Segments(0))
+	assert.Equal(t, 1, Segments(6*time.Minute))
+	assert.Equal(t, 2, Segments(6*time.Minute+time.Second))
+	assert.Equal(t, 20, Segments(2*time.Hour))
+}
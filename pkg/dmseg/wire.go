@@ -0,0 +1,99 @@
+package dmseg
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// The protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("dmseg: truncated message")
+
+// decoder reads the fields of a protobuf message.
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) done() bool {
+	return d.pos >= len(d.data)
+}
+
+func (d *decoder) varint() (uint64, error) {
+	v, n := binary.Uvarint(d.data[d.pos:])
+	if n == 0 {
+		return 0, errTruncated
+	}
+	if n < 0 {
+		return 0, errors.New("dmseg: varint overflows 64 bits")
+	}
+	d.pos += n
+	return v, nil
+}
+
+// key reads the field number and the wire type of the next field.
+func (d *decoder) key() (int, int, error) {
+	key, err := d.varint()
+	if err != nil {
+		return 0, 0, err
+	}
+	if key>>3 == 0 || key>>3 > math.MaxInt32 {
+		return 0, 0, fmt.Errorf("dmseg: invalid field number %d", key>>3)
+	}
+	return int(key >> 3), int(key & 7), nil
+}
+
+func (d *decoder) bytes() ([]byte, error) {
+	length, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(len(d.data)-d.pos) {
+		return nil, errTruncated
+	}
+	b := d.data[d.pos : d.pos+int(length)]
+	d.pos += int(length)
+	return b, nil
+}
+
+// skip skips the value of the wire type, so unknown fields are ignored.
+func (d *decoder) skip(wireType int) error {
+	switch wireType {
+	case wireVarint:
+		_, err := d.varint()
+		return err
+	case wireFixed64:
+		return d.advance(8)
+	case wireBytes:
+		_, err := d.bytes()
+		return err
+	case wireFixed32:
+		return d.advance(4)
+	default:
+		return fmt.Errorf("dmseg: unsupported wire type %d", wireType)
+	}
+}
+
+func (d *decoder) advance(n int) error {
+	if len(d.data)-d.pos < n {
+		return errTruncated
+	}
+	d.pos += n
+	return nil
+}
+
+// expect fails when the field is not of the wire type the message declares.
+func expect(field, wireType, want int) error {
+	if wireType != want {
+		return fmt.Errorf("dmseg: field %d has wire type %d, want %d", field, wireType, want)
+	}
+	return nil
+}
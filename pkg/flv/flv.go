@@ -0,0 +1,149 @@
+package flv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The types of the tags.
+const (
+	TagAudio  = 8
+	TagVideo  = 9
+	TagScript = 18
+)
+
+const (
+	headerSize = 9
+	tagSize    = 11
+	// maxDataSize is the largest size the 24 bits data size holds.
+	maxDataSize = 1<<24 - 1
+)
+
+const (
+	codecAVC  = 7
+	codecHEVC = 12
+	codecAAC  = 10
+)
+
+var ErrInvalidHeader = errors.New("flv: invalid header")
+
+// Header tells which kinds of tags the stream holds.
+type Header struct {
+	Audio bool
+	Video bool
+}
+
+// Tag is an audio, video or script tag, Timestamp is in milliseconds.
+type Tag struct {
+	Type      uint8
+	Timestamp uint32
+	Data      []byte
+}
+
+// IsKeyframe reports whether the tag is a video keyframe, a file can start from it.
+func (tag Tag) IsKeyframe() bool {
+	return tag.Type == TagVideo && len(tag.Data) > 0 && tag.Data[0]>>4 == 1
+}
+
+// IsSequenceHeader reports whether the tag is the AVC/HEVC decoder configuration or the AAC specific config,
+// which a file needs before the frames.
+func (tag Tag) IsSequenceHeader() bool {
+	if len(tag.Data) < 2 {
+		return false
+	}
+	switch tag.Type {
+	case TagVideo:
+		codec := tag.Data[0] & 0x0F
+		return (codec == codecAVC || codec == codecHEVC) && tag.Data[1] == 0
+	case TagAudio:
+		return tag.Data[0]>>4 == codecAAC && tag.Data[1] == 0
+	}
+	return false
+}
+
+// Reader reads the tags of a flv stream.
+type Reader struct {
+	r      io.Reader
+	header []byte
+}
+
+// NewReader reads the header of the stream.
+func NewReader(r io.Reader) (*Reader, Header, error) {
+	// the header is followed by the size of the tag before the first one, which is 0
+	buf := make([]byte, headerSize+4)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, Header{}, err
+	}
+	if !bytes.HasPrefix(buf, []byte("FLV")) || binary.BigEndian.Uint32(buf[5:9]) < headerSize {
+		return nil, Header{}, ErrInvalidHeader
+	}
+	// a longer header is skipped
+	if skip := int64(binary.BigEndian.Uint32(buf[5:9])) - headerSize; skip > 0 {
+		if _, err := io.CopyN(io.Discard, r, skip); err != nil {
+			return nil, Header{}, err
+		}
+	}
+	header := Header{Audio: buf[4]&0x04 != 0, Video: buf[4]&0x01 != 0}
+	return &Reader{r: r, header: make([]byte, tagSize)}, header, nil
+}
+
+// ReadTag reads the next tag, io.EOF is returned at the end of the stream.
+func (r *Reader) ReadTag() (Tag, error) {
+	if _, err := io.ReadFull(r.r, r.header); err != nil {
+		return Tag{}, err
+	}
+	tag := Tag{
+		Type: r.header[0] & 0x1F,
+		// the lower 24 bits followed by the upper 8 bits
+		Timestamp: uint32(r.header[4])<<16 | uint32(r.header[5])<<8 | uint32(r.header[6]) | uint32(r.header[7])<<24,
+	}
+	size := int(r.header[1])<<16 | int(r.header[2])<<8 | int(r.header[3])
+	tag.Data = make([]byte, size+4)
+	if _, err := io.ReadFull(r.r, tag.Data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return Tag{}, err
+	}
+	tag.Data = tag.Data[:size]
+	return tag, nil
+}
+
+// Writer writes a flv stream.
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter writes the header of the stream.
+func NewWriter(w io.Writer, header Header) (*Writer, error) {
+	buf := []byte{'F', 'L', 'V', 1, 0, 0, 0, 0, headerSize, 0, 0, 0, 0}
+	if header.Audio {
+		buf[4] |= 0x04
+	}
+	if header.Video {
+		buf[4] |= 0x01
+	}
+	if _, err := w.Write(buf); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w}, nil
+}
+
+// WriteTag writes the tag and the size of it.
+func (w *Writer) WriteTag(tag Tag) error {
+	if len(tag.Data) > maxDataSize {
+		return fmt.Errorf("flv: tag of %d bytes is too large", len(tag.Data))
+	}
+	buf := make([]byte, tagSize, tagSize+len(tag.Data)+4)
+	buf[0] = tag.Type
+	buf[1], buf[2], buf[3] = byte(len(tag.Data)>>16), byte(len(tag.Data)>>8), byte(len(tag.Data))
+	buf[4], buf[5], buf[6], buf[7] = byte(tag.Timestamp>>16), byte(tag.Timestamp>>8), byte(tag.Timestamp), byte(tag.Timestamp>>24)
+	buf = append(buf, tag.Data...)
+	buf = append(buf, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(buf[len(buf)-4:], uint32(tagSize+len(tag.Data)))
+	_, err := w.w.Write(buf)
+	return err
+}
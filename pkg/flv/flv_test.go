@@ -0,0 +1,60 @@
+package flv
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadWrite(t *testing.T) {
+	tags := []Tag{
+		{Type: TagScript, Data: []byte{2, 0, 10}},
+		{Type: TagVideo, Data: []byte{0x17, 0, 0, 0, 0}},
+		{Type: TagAudio, Data: []byte{0xAF, 0, 0x12, 0x10}},
+		{Type: TagVideo, Timestamp: 0x01020304, Data: []byte{0x17, 1, 0, 0, 0, 0xAA}},
+		{Type: TagAudio, Timestamp: 23, Data: []byte{0xAF, 1, 0xBB}},
+		{Type: TagVideo, Timestamp: 40, Data: []byte{0x27, 1, 0, 0, 0, 0xCC}},
+	}
+	var buf bytes.Buffer
+	writer, err := NewWriter(&buf, Header{Audio: true, Video: true})
+	assert.NoError(t, err)
+	for _, tag := range tags {
+		assert.NoError(t, writer.WriteTag(tag))
+	}
+	assert.Equal(t, []byte{'F', 'L', 'V', 1, 5, 0, 0, 0, 9, 0, 0, 0, 0}, buf.Bytes()[:13])
+
+	reader, header, err := NewReader(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, Header{Audio: true, Video: true}, header)
+	for _, tag := range tags {
+		read, err := reader.ReadTag()
+		assert.NoError(t, err)
+		assert.Equal(t, tag, read)
+	}
+	_, err = reader.ReadTag()
+	assert.Equal(t, io.EOF, err)
+
+	assert.True(t, tags[1].IsSequenceHeader())
+	assert.True(t, tags[1].IsKeyframe())
+	assert.True(t, tags[2].IsSequenceHeader())
+	assert.False(t, tags[3].IsSequenceHeader())
+	assert.True(t, tags[3].IsKeyframe())
+	assert.False(t, tags[4].IsSequenceHeader())
+	assert.False(t, tags[5].IsKeyframe())
+}
+
+func TestReadInvalid(t *testing.T) {
+	_, _, err := NewReader(bytes.NewReader([]byte("<html></html>")))
+	assert.Equal(t, ErrInvalidHeader, err)
+
+	var buf bytes.Buffer
+	writer, err := NewWriter(&buf, Header{Video: true})
+	assert.NoError(t, err)
+	assert.NoError(t, writer.WriteTag(Tag{Type: TagVideo, Data: []byte{0x17, 1, 0, 0, 0}}))
+	reader, _, err := NewReader(bytes.NewReader(buf.Bytes()[:buf.Len()-2]))
+	assert.NoError(t, err)
+	_, err = reader.ReadTag()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
@@ -0,0 +1,138 @@
+package hls
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidPlaylist = errors.New("hls: invalid playlist")
+
+// Segment is a media segment, Sequence is its media sequence number.
+type Segment struct {
+	URI      string
+	Duration time.Duration
+	Sequence int64
+}
+
+// Playlist is a media playlist, or a master playlist when Variants is not empty.
+type Playlist struct {
+	TargetDuration time.Duration
+	MediaSequence  int64
+	// Map is the URI of the initialization section of fragmented mp4 segments.
+	Map      string
+	Segments []Segment
+	// EndList is set when no segment is appended any more.
+	EndList  bool
+	Variants []string
+}
+
+// Parse parses the playlist, the URIs are resolved against base.
+func Parse(r io.Reader, base *url.URL) (*Playlist, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "#EXTM3U" {
+		return nil, ErrInvalidPlaylist
+	}
+	playlist := &Playlist{}
+	var (
+		duration time.Duration
+		variant  bool
+	)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case len(line) == 0:
+		case strings.HasPrefix(line, "#EXT-X-TARGETDURATION:"):
+			seconds, err := strconv.ParseFloat(strings.TrimPrefix(line, "#EXT-X-TARGETDURATION:"), 64)
+			if err != nil {
+				return nil, ErrInvalidPlaylist
+			}
+			playlist.TargetDuration = time.Duration(seconds * float64(time.Second))
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			sequence, err := strconv.ParseInt(strings.TrimPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"), 10, 64)
+			if err != nil {
+				return nil, ErrInvalidPlaylist
+			}
+			playlist.MediaSequence = sequence
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			uri, ok := attribute(strings.TrimPrefix(line, "#EXT-X-MAP:"), "URI")
+			if !ok {
+				return nil, ErrInvalidPlaylist
+			}
+			playlist.Map = resolve(base, uri)
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value := strings.TrimPrefix(line, "#EXTINF:")
+			if i := strings.IndexByte(value, ','); i >= 0 {
+				value = value[:i]
+			}
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, ErrInvalidPlaylist
+			}
+			duration = time.Duration(seconds * float64(time.Second))
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			variant = true
+		case line == "#EXT-X-ENDLIST":
+			playlist.EndList = true
+		case strings.HasPrefix(line, "#"):
+		case variant:
+			playlist.Variants = append(playlist.Variants, resolve(base, line))
+			variant = false
+		default:
+			playlist.Segments = append(playlist.Segments, Segment{
+				URI:      resolve(base, line),
+				Duration: duration,
+				Sequence: playlist.MediaSequence + int64(len(playlist.Segments)),
+			})
+			duration = 0
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return playlist, nil
+}
+
+// attribute returns the value of the attribute of the list like URI="init.m4s",BYTERANGE="720@0".
+func attribute(list, name string) (string, bool) {
+	for len(list) != 0 {
+		eq := strings.IndexByte(list, '=')
+		if eq < 0 {
+			return "", false
+		}
+		key, rest := strings.TrimSpace(list[:eq]), list[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return "", false
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else if comma := strings.IndexByte(rest, ','); comma >= 0 {
+			value, rest = rest[:comma], rest[comma:]
+		} else {
+			value, rest = rest, ""
+		}
+		if key == name {
+			return value, true
+		}
+		list = strings.TrimPrefix(rest, ",")
+	}
+	return "", false
+}
+
+func resolve(base *url.URL, uri string) string {
+	if base == nil {
+		return uri
+	}
+	ref, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	return base.ResolveReference(ref).String()
+}
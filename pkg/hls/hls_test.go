@@ -0,0 +1,46 @@
+package hls
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	base, _ := url.Parse("https://cn-gotcha.bilivideo.com/live-bvc/123/live_1_2/index.m3u8?expires=1")
+	playlist, err := Parse(strings.NewReader(`#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:1
+#EXT-X-MEDIA-SEQUENCE:41
+#EXT-X-MAP:URI="h1700000000.m4s",BYTERANGE="720@0"
+#EXTINF:1.00,a|b
+41.m4s
+#EXTINF:0.98,
+https://other.bilivideo.com/42.m4s
+`), base)
+	assert.NoError(t, err)
+	assert.Equal(t, &Playlist{
+		TargetDuration: time.Second,
+		MediaSequence:  41,
+		Map:            "https://cn-gotcha.bilivideo.com/live-bvc/123/live_1_2/h1700000000.m4s",
+		Segments: []Segment{
+			{URI: "https://cn-gotcha.bilivideo.com/live-bvc/123/live_1_2/41.m4s", Duration: time.Second, Sequence: 41},
+			{URI: "https://other.bilivideo.com/42.m4s", Duration: 980 * time.Millisecond, Sequence: 42},
+		},
+	}, playlist)
+}
+
+func TestParseMaster(t *testing.T) {
+	playlist, err := Parse(strings.NewReader("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1000\n/live/index.m3u8\n#EXT-X-ENDLIST\n"),
+		&url.URL{Scheme: "https", Host: "example.com", Path: "/master.m3u8"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://example.com/live/index.m3u8"}, playlist.Variants)
+	assert.Empty(t, playlist.Segments)
+	assert.True(t, playlist.EndList)
+
+	_, err = Parse(strings.NewReader("<html>"), nil)
+	assert.Equal(t, ErrInvalidPlaylist, err)
+}
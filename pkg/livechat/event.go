@@ -0,0 +1,224 @@
+package livechat
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// The commands of the messages which are decoded into events.
+const (
+	CmdDanmaku   = "DANMU_MSG"
+	CmdGift      = "SEND_GIFT"
+	CmdSuperChat = "SUPER_CHAT_MESSAGE"
+	CmdInteract  = "INTERACT_WORD"
+)
+
+// The types of Interact.
+const (
+	InteractEnter         = 1
+	InteractFollow        = 2
+	InteractShare         = 3
+	InteractSpecialFollow = 4
+	InteractMutualFollow  = 5
+)
+
+// Event is a *Danmaku, *Gift, *SuperChat or *Interact.
+type Event interface {
+	Cmd() string
+}
+
+// User is who sends the event, the name is masked when the chat is connected without the login.
+type User struct {
+	Uid        int64  `json:"uid"`
+	Uname      string `json:"uname"`
+	MedalName  string `json:"medal_name,omitempty"`
+	MedalLevel int    `json:"medal_level,omitempty"`
+}
+
+type Danmaku struct {
+	User
+	Time     time.Time `json:"time"`
+	Content  string    `json:"content"`
+	Mode     int       `json:"mode"`
+	FontSize int       `json:"font_size"`
+	Color    uint32    `json:"color"`
+}
+
+type Gift struct {
+	User
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	GiftID   int64     `json:"gift_id"`
+	GiftName string    `json:"gift_name"`
+	Num      int       `json:"num"`
+	// CoinType is gold for the paid gifts, TotalCoin of which is 1000 for 1 yuan.
+	CoinType  string `json:"coin_type"`
+	TotalCoin int64  `json:"total_coin"`
+}
+
+type SuperChat struct {
+	User
+	Time    time.Time `json:"time"`
+	ID      int64     `json:"id"`
+	Message string    `json:"message"`
+	// Price is in yuan.
+	Price    int           `json:"price"`
+	Duration time.Duration `json:"duration"`
+}
+
+type Interact struct {
+	User
+	Time time.Time `json:"time"`
+	Type int       `json:"type"`
+}
+
+func (*Danmaku) Cmd() string   { return CmdDanmaku }
+func (*Gift) Cmd() string      { return CmdGift }
+func (*SuperChat) Cmd() string { return CmdSuperChat }
+func (*Interact) Cmd() string  { return CmdInteract }
+
+type medalInfo struct {
+	MedalName  string `json:"medal_name"`
+	MedalLevel int    `json:"medal_level"`
+}
+
+// ParseMessage decodes the body of a message packet, the event is nil for the other commands.
+func ParseMessage(body []byte) (Event, error) {
+	message := struct {
+		Cmd  string            `json:"cmd"`
+		Info []json.RawMessage `json:"info"`
+		Data json.RawMessage   `json:"data"`
+	}{}
+	if err := json.Unmarshal(body, &message); err != nil {
+		return nil, err
+	}
+	// the command may carry options like DANMU_MSG:4:0:2:2:2:0
+	cmd := message.Cmd
+	if i := strings.IndexByte(cmd, ':'); i >= 0 {
+		cmd = cmd[:i]
+	}
+	switch cmd {
+	case CmdDanmaku:
+		return parseDanmaku(message.Info)
+	case CmdGift:
+		data := struct {
+			Uid       int64     `json:"uid"`
+			Uname     string    `json:"uname"`
+			Timestamp int64     `json:"timestamp"`
+			Action    string    `json:"action"`
+			GiftID    int64     `json:"giftId"`
+			GiftName  string    `json:"giftName"`
+			Num       int       `json:"num"`
+			CoinType  string    `json:"coin_type"`
+			TotalCoin int64     `json:"total_coin"`
+			MedalInfo medalInfo `json:"medal_info"`
+		}{}
+		if err := json.Unmarshal(message.Data, &data); err != nil {
+			return nil, err
+		}
+		return &Gift{
+			User:      User{Uid: data.Uid, Uname: data.Uname, MedalName: data.MedalInfo.MedalName, MedalLevel: data.MedalInfo.MedalLevel},
+			Time:      unixTime(data.Timestamp),
+			Action:    data.Action,
+			GiftID:    data.GiftID,
+			GiftName:  data.GiftName,
+			Num:       data.Num,
+			CoinType:  data.CoinType,
+			TotalCoin: data.TotalCoin,
+		}, nil
+	case CmdSuperChat:
+		data := struct {
+			ID        int64  `json:"id"`
+			Uid       int64  `json:"uid"`
+			Price     int    `json:"price"`
+			Message   string `json:"message"`
+			Time      int    `json:"time"`
+			StartTime int64  `json:"start_time"`
+			UserInfo  struct {
+				Uname string `json:"uname"`
+			} `json:"user_info"`
+			MedalInfo medalInfo `json:"medal_info"`
+		}{}
+		if err := json.Unmarshal(message.Data, &data); err != nil {
+			return nil, err
+		}
+		return &SuperChat{
+			User:     User{Uid: data.Uid, Uname: data.UserInfo.Uname, MedalName: data.MedalInfo.MedalName, MedalLevel: data.MedalInfo.MedalLevel},
+			Time:     unixTime(data.StartTime),
+			ID:       data.ID,
+			Message:  data.Message,
+			Price:    data.Price,
+			Duration: time.Duration(data.Time) * time.Second,
+		}, nil
+	case CmdInteract:
+		data := struct {
+			Uid       int64     `json:"uid"`
+			Uname     string    `json:"uname"`
+			MsgType   int       `json:"msg_type"`
+			Timestamp int64     `json:"timestamp"`
+			FansMedal medalInfo `json:"fans_medal"`
+		}{}
+		if err := json.Unmarshal(message.Data, &data); err != nil {
+			return nil, err
+		}
+		return &Interact{
+			User: User{Uid: data.Uid, Uname: data.Uname, MedalName: data.FansMedal.MedalName, MedalLevel: data.FansMedal.MedalLevel},
+			Time: unixTime(data.Timestamp),
+			Type: data.MsgType,
+		}, nil
+	}
+	return nil, nil
+}
+
+// parseDanmaku decodes the info array of DANMU_MSG:
+// [[0, mode, font size, color, timestamp in ms, ...], content, [uid, uname, ...], [medal level, medal name, ...], ...]
+func parseDanmaku(info []json.RawMessage) (Event, error) {
+	if len(info) < 3 {
+		return nil, ErrInvalidPacket
+	}
+	var (
+		properties []json.RawMessage
+		user       []json.RawMessage
+		medal      []json.RawMessage
+		danmaku    = &Danmaku{}
+	)
+	if err := json.Unmarshal(info[0], &properties); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(info[1], &danmaku.Content); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(info[2], &user); err != nil {
+		return nil, err
+	}
+	if len(properties) < 5 || len(user) < 2 {
+		return nil, ErrInvalidPacket
+	}
+	var timestamp int64
+	for i, v := range []interface{}{&danmaku.Mode, &danmaku.FontSize, &danmaku.Color, &timestamp} {
+		if err := json.Unmarshal(properties[i+1], v); err != nil {
+			return nil, err
+		}
+	}
+	danmaku.Time = time.UnixMilli(timestamp)
+	if err := json.Unmarshal(user[0], &danmaku.Uid); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(user[1], &danmaku.Uname); err != nil {
+		return nil, err
+	}
+	// the medal is an empty array without one
+	if len(info) > 3 && json.Unmarshal(info[3], &medal) == nil && len(medal) >= 2 {
+		json.Unmarshal(medal[0], &danmaku.MedalLevel)
+		json.Unmarshal(medal[1], &danmaku.MedalName)
+	}
+	return danmaku, nil
+}
+
+func unixTime(timestamp int64) time.Time {
+	if timestamp <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(timestamp, 0)
+}
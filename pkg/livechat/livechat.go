@@ -0,0 +1,198 @@
+package livechat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	origin    = "https://live.bilibili.com"
+	userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/117.0.0.0 Safari/537.36"
+	// heartbeatBody is what the web player sends
+	heartbeatBody = "[object Object]"
+)
+
+// Auth is the body of the auth packet, Key is the token of the danmu info and Uid is 0 without the login.
+type Auth struct {
+	Uid      int64  `json:"uid"`
+	RoomID   int64  `json:"roomid"`
+	ProtoVer int    `json:"protover"`
+	Buvid    string `json:"buvid,omitempty"`
+	Platform string `json:"platform"`
+	Type     int    `json:"type"`
+	Key      string `json:"key"`
+}
+
+// AuthError is returned when the server refuses the auth packet.
+type AuthError struct {
+	Code int
+}
+
+func (err AuthError) Error() string {
+	return fmt.Sprintf("livechat: auth failed with code %d", err.Code)
+}
+
+// Client connects the chat of a room.
+type Client struct {
+	// Urls are the websocket servers like wss://broadcastlv.chat.bilibili.com:443/sub, they are tried in order.
+	Urls []string
+	Auth Auth
+	// HeartbeatInterval defaults to 30s, the connection is dropped when nothing is received in two intervals.
+	HeartbeatInterval time.Duration
+}
+
+func NewClient(roomID, uid int64, key string, urls []string) *Client {
+	return &Client{
+		Urls: urls,
+		Auth: Auth{
+			Uid:      uid,
+			RoomID:   roomID,
+			ProtoVer: ProtoBrotli,
+			Platform: "web",
+			Type:     2,
+			Key:      key,
+		},
+		HeartbeatInterval: 30 * time.Second,
+	}
+}
+
+// Run connects the first available server and sends the events to events until the connection fails or ctx is canceled.
+// It never closes events, the caller reconnects with a new token after it returns.
+func (c *Client) Run(ctx context.Context, events chan<- Event) error {
+	if len(c.Urls) == 0 {
+		return errors.New("livechat: no server")
+	}
+	var err error
+	for _, url := range c.Urls {
+		var conn *websocket.Conn
+		if conn, err = c.dial(url); err != nil {
+			continue
+		}
+		return c.serve(ctx, conn, events)
+	}
+	return err
+}
+
+func (c *Client) dial(url string) (*websocket.Conn, error) {
+	config, err := websocket.NewConfig(url, origin)
+	if err != nil {
+		return nil, err
+	}
+	config.Header.Set("User-Agent", userAgent)
+	config.Dialer = &net.Dialer{Timeout: 10 * time.Second}
+	return websocket.DialConfig(config)
+}
+
+func (c *Client) serve(ctx context.Context, conn *websocket.Conn, events chan<- Event) error {
+	var (
+		wg   sync.WaitGroup
+		done = make(chan struct{})
+	)
+	defer wg.Wait()
+	defer close(done)
+	// closing the connection interrupts the reading
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		conn.Close()
+	}()
+
+	auth, err := json.Marshal(c.Auth)
+	if err != nil {
+		return err
+	}
+	if err = c.send(conn, Packet{ProtoVer: ProtoInt, Op: OpAuth, Seq: 1, Body: auth}); err != nil {
+		return c.canceled(ctx, err)
+	}
+	packets, err := c.receive(conn)
+	if err != nil {
+		return c.canceled(ctx, err)
+	}
+	if len(packets) == 0 || packets[0].Op != OpAuthReply {
+		return ErrInvalidPacket
+	}
+	reply := struct {
+		Code int `json:"code"`
+	}{}
+	if err = json.Unmarshal(packets[0].Body, &reply); err != nil {
+		return err
+	}
+	if reply.Code != 0 {
+		return AuthError{Code: reply.Code}
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(c.HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			// the connection is closed on failures, so the reading reports them
+			if c.send(conn, Packet{ProtoVer: ProtoInt, Op: OpHeartbeat, Seq: 1, Body: []byte(heartbeatBody)}) != nil {
+				conn.Close()
+				return
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	for {
+		packets, err := c.receive(conn)
+		if err != nil {
+			return c.canceled(ctx, err)
+		}
+		for _, packet := range packets {
+			if packet.Op != OpMessage {
+				continue
+			}
+			// a malformed message is dropped alone
+			event, err := ParseMessage(packet.Body)
+			if err != nil || event == nil {
+				continue
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+func (c *Client) send(conn *websocket.Conn, packet Packet) error {
+	return websocket.Message.Send(conn, packet.Marshal())
+}
+
+func (c *Client) receive(conn *websocket.Conn) ([]Packet, error) {
+	if err := conn.SetReadDeadline(time.Now().Add(2 * c.HeartbeatInterval)); err != nil {
+		return nil, err
+	}
+	var data []byte
+	if err := websocket.Message.Receive(conn, &data); err != nil {
+		return nil, err
+	}
+	return Unmarshal(data)
+}
+
+// canceled reports the cancellation rather than the error of the closed connection.
+func (c *Client) canceled(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
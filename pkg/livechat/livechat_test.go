@@ -0,0 +1,158 @@
+package livechat
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	danmakuMessage   = `{"cmd":"DANMU_MSG:4:0:2:2:2:0","info":[[0,1,25,16777215,1700000000123,0,0,"hash",0,0,0,"",0,{}],"hello",[1001,"alice",0,0,0,10000,1,""],[21,"medal","anchor",1,398668,0],[3,0,9868950,">50000"]]}`
+	giftMessage      = `{"cmd":"SEND_GIFT","data":{"action":"投喂","giftId":31036,"giftName":"小花花","num":2,"coin_type":"gold","total_coin":200,"timestamp":1700000001,"uid":1002,"uname":"bob","medal_info":{"medal_name":"medal","medal_level":3}}}`
+	superChatMessage = `{"cmd":"SUPER_CHAT_MESSAGE","data":{"id":7,"uid":1003,"price":30,"message":"hi","time":60,"start_time":1700000002,"user_info":{"uname":"carol"},"medal_info":{"medal_name":"","medal_level":0}}}`
+	interactMessage  = `{"cmd":"INTERACT_WORD","data":{"uid":1004,"uname":"dave","msg_type":2,"timestamp":1700000003,"fans_medal":{"medal_name":"","medal_level":0}}}`
+)
+
+func zlibPacket(t *testing.T, packets ...Packet) Packet {
+	var buf bytes.Buffer
+	writeCompressed(t, zlib.NewWriter(&buf), packets)
+	return Packet{ProtoVer: ProtoZlib, Op: OpMessage, Body: buf.Bytes()}
+}
+
+func brotliPacket(t *testing.T, packets ...Packet) Packet {
+	var buf bytes.Buffer
+	writeCompressed(t, brotli.NewWriter(&buf), packets)
+	return Packet{ProtoVer: ProtoBrotli, Op: OpMessage, Body: buf.Bytes()}
+}
+
+func writeCompressed(t *testing.T, writer io.WriteCloser, packets []Packet) {
+	for _, packet := range packets {
+		_, err := writer.Write(packet.Marshal())
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, writer.Close())
+}
+
+func TestUnmarshal(t *testing.T) {
+	heartbeat := Packet{ProtoVer: ProtoInt, Op: OpHeartbeatReply, Seq: 1, Body: []byte{0, 0, 0, 42}}
+	message := Packet{ProtoVer: ProtoJSON, Op: OpMessage, Body: []byte(giftMessage)}
+	compressed := zlibPacket(t, message, message)
+	data := append(heartbeat.Marshal(), compressed.Marshal()...)
+	packets, err := Unmarshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, []Packet{heartbeat, message, message}, packets)
+
+	_, err = Unmarshal(data[:len(data)-1])
+	assert.ErrorIs(t, err, ErrInvalidPacket)
+
+	// the server sends the messages brotli compressed for protover 3
+	danmaku := Packet{ProtoVer: ProtoJSON, Op: OpMessage, Body: []byte(danmakuMessage)}
+	packets, err = Unmarshal(brotliPacket(t, danmaku, message).Marshal())
+	assert.NoError(t, err)
+	assert.Equal(t, []Packet{danmaku, message}, packets)
+	event, err := ParseMessage(packets[0].Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", event.(*Danmaku).Content)
+	_, err = Unmarshal(Packet{ProtoVer: ProtoBrotli, Op: OpMessage, Body: []byte{1}}.Marshal())
+	assert.Error(t, err)
+}
+
+func TestParseMessage(t *testing.T) {
+	event, err := ParseMessage([]byte(danmakuMessage))
+	assert.NoError(t, err)
+	assert.Equal(t, &Danmaku{
+		User:     User{Uid: 1001, Uname: "alice", MedalName: "medal", MedalLevel: 21},
+		Time:     time.UnixMilli(1700000000123),
+		Content:  "hello",
+		Mode:     1,
+		FontSize: 25,
+		Color:    16777215,
+	}, event)
+	event, err = ParseMessage([]byte(superChatMessage))
+	assert.NoError(t, err)
+	assert.Equal(t, &SuperChat{
+		User:     User{Uid: 1003, Uname: "carol"},
+		Time:     time.Unix(1700000002, 0),
+		ID:       7,
+		Message:  "hi",
+		Price:    30,
+		Duration: time.Minute,
+	}, event)
+	event, err = ParseMessage([]byte(`{"cmd":"ONLINE_RANK_COUNT","data":{"count":1}}`))
+	assert.NoError(t, err)
+	assert.Nil(t, event)
+}
+
+func TestClient(t *testing.T) {
+	server := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		var data []byte
+		if websocket.Message.Receive(conn, &data) != nil {
+			return
+		}
+		packets, err := Unmarshal(data)
+		assert.NoError(t, err)
+		auth := Auth{}
+		assert.NoError(t, json.Unmarshal(packets[0].Body, &auth))
+		assert.Equal(t, OpAuth, int(packets[0].Op))
+		assert.Equal(t, "token", auth.Key)
+		assert.Equal(t, int64(1), auth.RoomID)
+		assert.Equal(t, ProtoBrotli, auth.ProtoVer)
+
+		reply := Packet{ProtoVer: ProtoInt, Op: OpAuthReply, Body: []byte(`{"code":0}`)}
+		websocket.Message.Send(conn, reply.Marshal())
+		websocket.Message.Send(conn, brotliPacket(t,
+			Packet{Op: OpMessage, Body: []byte(danmakuMessage)},
+			Packet{Op: OpMessage, Body: []byte(`{"cmd":"ONLINE_RANK_COUNT","data":{"count":1}}`)},
+			Packet{Op: OpMessage, Body: []byte(giftMessage)},
+		).Marshal())
+		websocket.Message.Send(conn, Packet{Op: OpMessage, Body: []byte(superChatMessage)}.Marshal())
+		websocket.Message.Send(conn, Packet{Op: OpMessage, Body: []byte(interactMessage)}.Marshal())
+		// wait for the client to close the connection
+		for websocket.Message.Receive(conn, &data) == nil {
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := NewClient(1, 0, "token", []string{"ws" + strings.TrimPrefix(server.URL, "http")})
+	events := make(chan Event)
+	errs := make(chan error, 1)
+	go func() { errs <- client.Run(ctx, events) }()
+
+	cmds := make([]string, 0)
+	for len(cmds) < 4 {
+		select {
+		case event := <-events:
+			cmds = append(cmds, event.Cmd())
+		case err := <-errs:
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event is received")
+		}
+	}
+	assert.Equal(t, []string{CmdDanmaku, CmdGift, CmdSuperChat, CmdInteract}, cmds)
+	cancel()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+}
+
+func TestClientAuthError(t *testing.T) {
+	server := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		var data []byte
+		websocket.Message.Receive(conn, &data)
+		websocket.Message.Send(conn, Packet{ProtoVer: ProtoInt, Op: OpAuthReply, Body: []byte(`{"code":-101}`)}.Marshal())
+	}))
+	defer server.Close()
+	client := NewClient(1, 0, "token", []string{"ws" + strings.TrimPrefix(server.URL, "http")})
+	err := client.Run(context.Background(), make(chan Event))
+	assert.Equal(t, AuthError{Code: -101}, err)
+}
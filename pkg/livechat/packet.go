@@ -0,0 +1,105 @@
+package livechat
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/andybalholm/brotli"
+)
+
+// HeaderLen is the length of the packet header: the packet length, the header length, the protocol version,
+// the operation and the sequence, all in big endian.
+const HeaderLen = 16
+
+// The protocol versions, they tell how the body is encoded.
+const (
+	ProtoJSON = 0
+	// ProtoInt is the version of the auth, heartbeat and popularity packets.
+	ProtoInt = 1
+	// ProtoZlib bodies are zlib compressed packets.
+	ProtoZlib = 2
+	// ProtoBrotli bodies are brotli compressed packets.
+	ProtoBrotli = 3
+)
+
+// The operations of the packets.
+const (
+	OpHeartbeat      = 2
+	OpHeartbeatReply = 3
+	OpMessage        = 5
+	OpAuth           = 7
+	OpAuthReply      = 8
+)
+
+var ErrInvalidPacket = errors.New("livechat: invalid packet")
+
+type Packet struct {
+	ProtoVer uint16
+	Op       uint32
+	Seq      uint32
+	Body     []byte
+}
+
+func (p Packet) Marshal() []byte {
+	data := make([]byte, HeaderLen, HeaderLen+len(p.Body))
+	binary.BigEndian.PutUint32(data[0:], uint32(HeaderLen+len(p.Body)))
+	binary.BigEndian.PutUint16(data[4:], HeaderLen)
+	binary.BigEndian.PutUint16(data[6:], p.ProtoVer)
+	binary.BigEndian.PutUint32(data[8:], p.Op)
+	binary.BigEndian.PutUint32(data[12:], p.Seq)
+	return append(data, p.Body...)
+}
+
+// Unmarshal splits the packets of a websocket message, the compressed packets are expanded into the ones they carry.
+func Unmarshal(data []byte) ([]Packet, error) {
+	packets := make([]Packet, 0, 1)
+	for len(data) != 0 {
+		if len(data) < HeaderLen {
+			return nil, ErrInvalidPacket
+		}
+		packetLen := binary.BigEndian.Uint32(data[0:])
+		headerLen := binary.BigEndian.Uint16(data[4:])
+		if headerLen < HeaderLen || uint32(headerLen) > packetLen || packetLen > uint32(len(data)) {
+			return nil, ErrInvalidPacket
+		}
+		packet := Packet{
+			ProtoVer: binary.BigEndian.Uint16(data[6:]),
+			Op:       binary.BigEndian.Uint32(data[8:]),
+			Seq:      binary.BigEndian.Uint32(data[12:]),
+			Body:     data[headerLen:packetLen],
+		}
+		data = data[packetLen:]
+
+		if packet.Op != OpMessage {
+			packets = append(packets, packet)
+			continue
+		}
+		var reader io.Reader
+		switch packet.ProtoVer {
+		case ProtoZlib:
+			zlibReader, err := zlib.NewReader(bytes.NewReader(packet.Body))
+			if err != nil {
+				return nil, err
+			}
+			reader = zlibReader
+		case ProtoBrotli:
+			reader = brotli.NewReader(bytes.NewReader(packet.Body))
+		default:
+			packets = append(packets, packet)
+			continue
+		}
+		body, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		inner, err := Unmarshal(body)
+		if err != nil {
+			return nil, err
+		}
+		packets = append(packets, inner...)
+	}
+	return packets, nil
+}
@@ -0,0 +1,355 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+	"unicode/utf8"
+)
+
+// Metadata is the iTunes style metadata (moov/udta/meta/ilst) of a mp4 file.
+type Metadata struct {
+	Title   string
+	Artist  string
+	Album   string
+	Date    string
+	Comment string
+	Genre   string
+	// Credits are written as the composer.
+	Credits string
+	// Cover is a JPEG or PNG image.
+	Cover []byte
+	// Chapters are written as Nero chapters (udta/chpl).
+	Chapters []Chapter
+}
+
+// Chapter starts at Start, End is only a hint since the next chapter starts where it ends.
+type Chapter struct {
+	Start time.Duration
+	End   time.Duration
+	Title string
+}
+
+const (
+	dataTypeUTF8 = 1
+	dataTypeJPEG = 13
+	dataTypePNG  = 14
+)
+
+// ilst builds the item list box of the metadata, the empty items are skipped.
+func (metadata Metadata) ilst() []byte {
+	items := make([][]byte, 0, 8)
+	for _, item := range []struct {
+		typ   string
+		value string
+	}{
+		{"\xa9nam", metadata.Title},
+		{"\xa9ART", metadata.Artist},
+		{"\xa9alb", metadata.Album},
+		{"\xa9day", metadata.Date},
+		{"\xa9cmt", metadata.Comment},
+		{"\xa9gen", metadata.Genre},
+		{"\xa9wrt", metadata.Credits},
+	} {
+		if len(item.value) != 0 {
+			items = append(items, makeBox(item.typ, dataBox(dataTypeUTF8, []byte(item.value))))
+		}
+	}
+	if len(metadata.Cover) != 0 {
+		typ := uint32(dataTypeJPEG)
+		if bytes.HasPrefix(metadata.Cover, []byte("\x89PNG")) {
+			typ = dataTypePNG
+		}
+		items = append(items, makeBox("covr", dataBox(typ, metadata.Cover)))
+	}
+	return makeBox("ilst", items...)
+}
+
+// meta builds the meta full box holding the item list.
+func (metadata Metadata) meta() []byte {
+	hdlr := make([]byte, 25)
+	copy(hdlr[8:12], "mdir")
+	copy(hdlr[12:16], "appl")
+	return makeBox("meta", make([]byte, 4), makeBox("hdlr", hdlr), metadata.ilst())
+}
+
+// chpl builds the Nero chapter box, it is nil without chapters.
+func (metadata Metadata) chpl() []byte {
+	if len(metadata.Chapters) == 0 {
+		return nil
+	}
+	chapters := metadata.Chapters
+	if len(chapters) > math.MaxUint8 {
+		chapters = chapters[:math.MaxUint8]
+	}
+	// version 1, flags, reserved and the chapter count
+	payload := []byte{1, 0, 0, 0, 0, 0, 0, 0, byte(len(chapters))}
+	for _, chapter := range chapters {
+		start := make([]byte, 8)
+		// in 100 nanoseconds
+		binary.BigEndian.PutUint64(start, uint64(chapter.Start/100))
+		title := []byte(chapter.Title)
+		if len(title) > math.MaxUint8 {
+			title = truncateUTF8(title, math.MaxUint8)
+		}
+		payload = append(payload, start...)
+		payload = append(payload, byte(len(title)))
+		payload = append(payload, title...)
+	}
+	return makeBox("chpl", payload)
+}
+
+func truncateUTF8(b []byte, max int) []byte {
+	for max > 0 && !utf8.RuneStart(b[max]) {
+		max--
+	}
+	return b[:max]
+}
+
+func dataBox(typ uint32, value []byte) []byte {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header[0:4], typ)
+	return makeBox("data", header, value)
+}
+
+func makeBox(typ string, payloads ...[]byte) []byte {
+	size := 8
+	for _, payload := range payloads {
+		size += len(payload)
+	}
+	box := make([]byte, 8, size)
+	binary.BigEndian.PutUint32(box[0:4], uint32(size))
+	copy(box[4:8], typ)
+	for _, payload := range payloads {
+		box = append(box, payload...)
+	}
+	return box
+}
+
+// SetMetadata copies the mp4 of r into w, replacing the metadata of its moov box.
+// The chunk offsets, and the absolute offsets of the fragments, are moved when the moov box grows in front of the media data.
+func SetMetadata(w io.Writer, r io.ReaderAt, size int64, metadata Metadata) error {
+	boxes, err := ReadBoxes(r, 0, size)
+	if err != nil {
+		return err
+	}
+	moov, ok := find(boxes, "moov")
+	if !ok {
+		return fmt.Errorf("mp4 box moov is missing")
+	}
+	payload, err := readFull(r, moov)
+	if err != nil {
+		return err
+	}
+	children, err := ReadBoxes(bytes.NewReader(payload), 0, int64(len(payload)))
+	if err != nil {
+		return err
+	}
+
+	newMoov := makeBox("moov", replaceMeta(payload, children, metadata.meta(), metadata.chpl()))
+	delta := int64(len(newMoov)) - moov.Size
+	if delta != 0 {
+		if err = moveChunkOffsets(newMoov[8:], moov.End(), delta); err != nil {
+			return err
+		}
+	}
+
+	if _, err = io.Copy(w, io.NewSectionReader(r, 0, moov.Offset)); err != nil {
+		return err
+	}
+	if _, err = w.Write(newMoov); err != nil {
+		return err
+	}
+	for _, box := range boxes {
+		if box.Offset < moov.End() {
+			continue
+		}
+		if delta == 0 || (box.Type != "moof" && box.Type != "mfra") {
+			if _, err = io.Copy(w, io.NewSectionReader(r, box.Offset, box.Size)); err != nil {
+				return err
+			}
+			continue
+		}
+		raw := make([]byte, box.Size)
+		if _, err = r.ReadAt(raw, box.Offset); err != nil {
+			return err
+		}
+		if err = moveFragmentOffsets(raw[box.HeaderSize:], moov.End(), delta); err != nil {
+			return err
+		}
+		if _, err = w.Write(raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceMeta returns the moov payload whose udta holds meta and chpl instead of the former ones.
+func replaceMeta(payload []byte, children []Box, meta, chpl []byte) []byte {
+	newPayload := make([]byte, 0, len(payload)+len(meta)+8)
+	udtaFound := false
+	for _, child := range children {
+		raw := payload[child.Offset:child.End()]
+		if child.Type != "udta" {
+			newPayload = append(newPayload, raw...)
+			continue
+		}
+		udtaFound = true
+		udta := raw[child.HeaderSize:]
+		items := make([][]byte, 0)
+		// a quicktime udta may end with a 32 bits terminator, such a udta is replaced as a whole
+		if boxes, err := ReadBoxes(bytes.NewReader(udta), 0, int64(len(udta))); err == nil {
+			for _, box := range boxes {
+				if box.Type != "meta" && box.Type != "chpl" {
+					items = append(items, udta[box.Offset:box.End()])
+				}
+			}
+		}
+		newPayload = append(newPayload, makeBox("udta", append(items, meta, chpl)...)...)
+	}
+	if !udtaFound {
+		newPayload = append(newPayload, makeBox("udta", meta, chpl)...)
+	}
+	return newPayload
+}
+
+// moveChunkOffsets adds delta to the stco/co64 offsets in the container which point at or after threshold.
+func moveChunkOffsets(container []byte, threshold, delta int64) error {
+	boxes, err := ReadBoxes(bytes.NewReader(container), 0, int64(len(container)))
+	if err != nil {
+		return err
+	}
+	for _, box := range boxes {
+		payload := container[box.Offset+box.HeaderSize : box.End()]
+		switch box.Type {
+		case "trak", "mdia", "minf", "stbl":
+			if err = moveChunkOffsets(payload, threshold, delta); err != nil {
+				return err
+			}
+		case "stco", "co64":
+			width := 4
+			if box.Type == "co64" {
+				width = 8
+			}
+			if len(payload) < 8 {
+				return fmt.Errorf("mp4 box %s is too short", box.Type)
+			}
+			count := int(binary.BigEndian.Uint32(payload[4:8]))
+			if len(payload) < 8+count*width {
+				return fmt.Errorf("mp4 box %s is too short", box.Type)
+			}
+			for i := 0; i < count; i++ {
+				if err = moveOffset(payload[8+i*width:8+(i+1)*width], threshold, delta); err != nil {
+					return fmt.Errorf("mp4 chunk offset of %s: %w", box.Type, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// moveFragmentOffsets adds delta to the offsets of the moof or mfra container which point at or after threshold.
+// They are the base data offsets of tfhd and the moof offsets of tfra, the others are relative to their moof.
+func moveFragmentOffsets(container []byte, threshold, delta int64) error {
+	boxes, err := ReadBoxes(bytes.NewReader(container), 0, int64(len(container)))
+	if err != nil {
+		return err
+	}
+	for _, box := range boxes {
+		payload := container[box.Offset+box.HeaderSize : box.End()]
+		switch box.Type {
+		case "traf":
+			if err = moveFragmentOffsets(payload, threshold, delta); err != nil {
+				return err
+			}
+		case "tfhd":
+			if len(payload) < 8 {
+				return fmt.Errorf("mp4 box %s is too short", box.Type)
+			}
+			// the base data offset is present with the flag 0x000001
+			if binary.BigEndian.Uint32(payload[0:4])&1 == 0 {
+				continue
+			}
+			if len(payload) < 16 {
+				return fmt.Errorf("mp4 box %s is too short", box.Type)
+			}
+			if err = moveOffset(payload[8:16], threshold, delta); err != nil {
+				return err
+			}
+		case "tfra":
+			if len(payload) < 16 {
+				return fmt.Errorf("mp4 box %s is too short", box.Type)
+			}
+			width := 4
+			if payload[0] == 1 {
+				width = 8
+			}
+			// the time and the moof offset are followed by the traf, trun and sample numbers of 1 to 4 bytes each
+			sizes := binary.BigEndian.Uint32(payload[8:12])
+			entrySize := 2*width + int(sizes>>4&3+sizes>>2&3+sizes&3) + 3
+			count := int(binary.BigEndian.Uint32(payload[12:16]))
+			if len(payload) < 16+count*entrySize {
+				return fmt.Errorf("mp4 box %s is too short", box.Type)
+			}
+			for i := 0; i < count; i++ {
+				entry := payload[16+i*entrySize+width:]
+				if err = moveOffset(entry[:width], threshold, delta); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// moveOffset adds delta to the 32 or 64 bits offset of entry if it points at or after threshold.
+func moveOffset(entry []byte, threshold, delta int64) error {
+	if len(entry) == 4 {
+		offset := int64(binary.BigEndian.Uint32(entry))
+		if offset < threshold {
+			return nil
+		}
+		if offset+delta > math.MaxUint32 {
+			return fmt.Errorf("mp4 offset %d overflows 32 bits", offset+delta)
+		}
+		binary.BigEndian.PutUint32(entry, uint32(offset+delta))
+		return nil
+	}
+	offset := int64(binary.BigEndian.Uint64(entry))
+	if offset >= threshold {
+		binary.BigEndian.PutUint64(entry, uint64(offset+delta))
+	}
+	return nil
+}
+
+// WriteMetadata replaces the metadata of the mp4 file of name through a temp file in the same directory.
+func WriteMetadata(name string, metadata Metadata) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), "bilibili_metadata_*"+filepath.Ext(name))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err = SetMetadata(tmp, file, stat.Size(), metadata); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	file.Close()
+	return os.Rename(tmp.Name(), name)
+}
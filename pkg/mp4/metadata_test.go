@@ -0,0 +1,82 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetMetadata(t *testing.T) {
+	mvhd := box("mvhd", u32(0, 0, 0, 1000, 5000), make([]byte, 80))
+	ftyp := box("ftyp", []byte("isom"), u32(512))
+	// moov is in front of mdat, so the chunk offset has to be moved
+	moovSize := 8 + len(mvhd) + 8 + 8 + 8 + 8 + 20 + len(box("udta", box("\xa9too", []byte("x"))))
+	chunk := uint32(len(ftyp) + moovSize + 8)
+	stbl := box("stbl", box("stco", u32(0, 1, chunk)))
+	moov := box("moov", mvhd, box("trak", box("mdia", box("minf", stbl))), box("udta", box("\xa9too", []byte("x"))))
+	assert.Equal(t, moovSize, len(moov))
+	file := bytes.Join([][]byte{ftyp, moov, box("mdat", []byte("media"))}, nil)
+	assert.Equal(t, "media", string(file[chunk:chunk+5]))
+
+	metadata := Metadata{
+		Title:    "标题",
+		Artist:   "up",
+		Genre:    "music",
+		Cover:    []byte("\xff\xd8jpeg"),
+		Chapters: []Chapter{{Start: 0, Title: "intro"}, {Start: 90 * time.Second, Title: "第二章"}},
+	}
+	var out bytes.Buffer
+	assert.NoError(t, SetMetadata(&out, bytes.NewReader(file), int64(len(file)), metadata))
+	tagged := out.Bytes()
+
+	info, err := Inspect(bytes.NewReader(tagged), int64(len(tagged)))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5000), info.Duration.Milliseconds())
+	assert.Contains(t, string(tagged), "\xa9nam")
+	assert.Contains(t, string(tagged), "标题")
+	assert.Contains(t, string(tagged), "\xa9too")
+
+	i := bytes.Index(tagged, []byte("chpl"))
+	assert.Equal(t, byte(2), tagged[i+12])
+	// the second chapter follows the start, length and title of "intro"
+	second := tagged[i+13+8+1+5:]
+	assert.Equal(t, uint64(900_000_000), binary.BigEndian.Uint64(second[:8]))
+	assert.Equal(t, "第二章", string(second[9:9+int(second[8])]))
+
+	i = bytes.Index(tagged, []byte("stco"))
+	moved := binary.BigEndian.Uint32(tagged[i+12 : i+16])
+	assert.Equal(t, "media", string(tagged[moved:moved+5]))
+
+	// the former metadata is replaced rather than appended
+	var again bytes.Buffer
+	assert.NoError(t, SetMetadata(&again, bytes.NewReader(tagged), int64(len(tagged)), metadata))
+	assert.Equal(t, tagged, again.Bytes())
+}
+
+func TestSetMetadataFragmented(t *testing.T) {
+	mvhd := box("mvhd", u32(0, 0, 0, 1000, 0), make([]byte, 80))
+	ftyp := box("ftyp", []byte("iso5"), u32(1))
+	moov := box("moov", mvhd, box("mvex", box("trex", make([]byte, 24))))
+	// tfhd has an explicit base data offset pointing at the media, and tfra points at the moof
+	moofOffset := uint32(len(ftyp) + len(moov))
+	moofSize := uint32(len(box("moof", box("traf", box("tfhd", u32(1, 1, 0, 0))))))
+	base := moofOffset + moofSize + 8
+	moof := box("moof", box("traf", box("tfhd", u32(1, 1, 0, base))))
+	mfra := box("mfra", box("tfra", u32(0, 1, 0, 1, 0, moofOffset), []byte{1, 1, 1}), box("mfro", u32(0, 0)))
+	file := bytes.Join([][]byte{ftyp, moov, moof, box("mdat", []byte("media")), mfra}, nil)
+	assert.Equal(t, "media", string(file[base:base+5]))
+
+	var out bytes.Buffer
+	assert.NoError(t, SetMetadata(&out, bytes.NewReader(file), int64(len(file)), Metadata{Title: "a"}))
+	tagged := out.Bytes()
+	assert.Contains(t, string(tagged), "\xa9nam")
+	i := bytes.Index(tagged, []byte("tfhd"))
+	moved := binary.BigEndian.Uint64(tagged[i+12 : i+20])
+	assert.Equal(t, "media", string(tagged[moved:moved+5]))
+	i = bytes.Index(tagged, []byte("tfra"))
+	movedMoof := binary.BigEndian.Uint32(tagged[i+24 : i+28])
+	assert.Equal(t, "moof", string(tagged[movedMoof+4:movedMoof+8]))
+}
@@ -0,0 +1,254 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Box is an ISO base media file format box, Offset is where its header starts.
+type Box struct {
+	Type       string
+	Offset     int64
+	Size       int64
+	HeaderSize int64
+}
+
+// End is the offset right after the box.
+func (box Box) End() int64 {
+	return box.Offset + box.Size
+}
+
+// ErrTruncated is returned when a box runs past the end of its container.
+type ErrTruncated struct {
+	Box  string
+	End  int64
+	Size int64
+}
+
+func (err ErrTruncated) Error() string {
+	return fmt.Sprintf("mp4 box %s is truncated: ends at %d, but the container ends at %d", err.Box, err.End, err.Size)
+}
+
+// ReadBoxes reads the boxes of r between offset and end.
+func ReadBoxes(r io.ReaderAt, offset, end int64) ([]Box, error) {
+	boxes := make([]Box, 0)
+	header := make([]byte, 16)
+	for offset < end {
+		if end-offset < 8 {
+			return boxes, ErrTruncated{Box: "header", End: offset + 8, Size: end}
+		}
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return boxes, err
+		}
+		box := Box{
+			Type:       string(header[4:8]),
+			Offset:     offset,
+			Size:       int64(binary.BigEndian.Uint32(header[:4])),
+			HeaderSize: 8,
+		}
+		switch box.Size {
+		case 0:
+			// the box extends to the end of the container
+			box.Size = end - offset
+		case 1:
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return boxes, err
+			}
+			box.Size = int64(binary.BigEndian.Uint64(header[8:16]))
+			box.HeaderSize = 16
+		}
+		if box.Size < box.HeaderSize {
+			return boxes, fmt.Errorf("mp4 box %s at %d has invalid size %d", box.Type, offset, box.Size)
+		}
+		if box.End() > end {
+			return boxes, ErrTruncated{Box: box.Type, End: box.End(), Size: end}
+		}
+		boxes = append(boxes, box)
+		offset = box.End()
+	}
+	return boxes, nil
+}
+
+// Info is the structure of a mp4 file.
+type Info struct {
+	Boxes      []Box
+	Fragmented bool
+	// Duration is zero when the file does not declare it.
+	Duration time.Duration
+}
+
+// Inspect reads the top level boxes of a mp4 file of size bytes and checks it is complete.
+func Inspect(r io.ReaderAt, size int64) (*Info, error) {
+	boxes, err := ReadBoxes(r, 0, size)
+	if err != nil {
+		return nil, err
+	}
+	info := &Info{Boxes: boxes}
+	moov, ok := find(boxes, "moov")
+	if !ok {
+		return nil, fmt.Errorf("mp4 box moov is missing")
+	}
+	children, err := ReadBoxes(r, moov.Offset+moov.HeaderSize, moov.End())
+	if err != nil {
+		return nil, err
+	}
+	timescale, duration, err := readMvhd(r, children)
+	if err != nil {
+		return nil, err
+	}
+	if mvex, ok := find(children, "mvex"); ok {
+		info.Fragmented = true
+		if duration == 0 {
+			if duration, err = readMehd(r, mvex); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if timescale != 0 && duration != 0 {
+		info.Duration = scale(duration, timescale)
+	}
+
+	if sidx, ok := find(boxes, "sidx"); ok {
+		info.Fragmented = true
+		d, err := checkSidx(r, sidx, size)
+		if err != nil {
+			return nil, err
+		}
+		if info.Duration == 0 {
+			info.Duration = d
+		}
+	}
+	if !info.Fragmented {
+		if _, ok = find(boxes, "mdat"); !ok {
+			return nil, fmt.Errorf("mp4 box mdat is missing")
+		}
+	}
+	return info, nil
+}
+
+// InspectFile inspects the mp4 file of name.
+func InspectFile(name string) (*Info, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return Inspect(file, stat.Size())
+}
+
+func find(boxes []Box, typ string) (Box, bool) {
+	for _, box := range boxes {
+		if box.Type == typ {
+			return box, true
+		}
+	}
+	return Box{}, false
+}
+
+func scale(duration, timescale uint64) time.Duration {
+	return time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+}
+
+func readFull(r io.ReaderAt, box Box) ([]byte, error) {
+	buf := make([]byte, box.Size-box.HeaderSize)
+	if _, err := r.ReadAt(buf, box.Offset+box.HeaderSize); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func readMvhd(r io.ReaderAt, moov []Box) (timescale, duration uint64, err error) {
+	mvhd, ok := find(moov, "mvhd")
+	if !ok {
+		return 0, 0, fmt.Errorf("mp4 box mvhd is missing")
+	}
+	buf, err := readFull(r, mvhd)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(buf) >= 32 && buf[0] == 1 {
+		return uint64(binary.BigEndian.Uint32(buf[20:24])), binary.BigEndian.Uint64(buf[24:32]), nil
+	}
+	if len(buf) >= 20 {
+		return uint64(binary.BigEndian.Uint32(buf[12:16])), uint64(binary.BigEndian.Uint32(buf[16:20])), nil
+	}
+	return 0, 0, fmt.Errorf("mp4 box mvhd is too short")
+}
+
+func readMehd(r io.ReaderAt, mvex Box) (uint64, error) {
+	children, err := ReadBoxes(r, mvex.Offset+mvex.HeaderSize, mvex.End())
+	if err != nil {
+		return 0, err
+	}
+	mehd, ok := find(children, "mehd")
+	if !ok {
+		return 0, nil
+	}
+	buf, err := readFull(r, mehd)
+	if err != nil {
+		return 0, err
+	}
+	if len(buf) >= 12 && buf[0] == 1 {
+		return binary.BigEndian.Uint64(buf[4:12]), nil
+	}
+	if len(buf) >= 8 {
+		return uint64(binary.BigEndian.Uint32(buf[4:8])), nil
+	}
+	return 0, fmt.Errorf("mp4 box mehd is too short")
+}
+
+// checkSidx checks that the media referenced by the segment index is all present, and returns its duration.
+func checkSidx(r io.ReaderAt, sidx Box, size int64) (time.Duration, error) {
+	buf, err := readFull(r, sidx)
+	if err != nil {
+		return 0, err
+	}
+	if len(buf) < 12 {
+		return 0, fmt.Errorf("mp4 box sidx is too short")
+	}
+	timescale := uint64(binary.BigEndian.Uint32(buf[8:12]))
+	var firstOffset uint64
+	pos := 12
+	if buf[0] == 0 {
+		if len(buf) < pos+8 {
+			return 0, fmt.Errorf("mp4 box sidx is too short")
+		}
+		firstOffset = uint64(binary.BigEndian.Uint32(buf[pos+4 : pos+8]))
+		pos += 8
+	} else {
+		if len(buf) < pos+16 {
+			return 0, fmt.Errorf("mp4 box sidx is too short")
+		}
+		firstOffset = binary.BigEndian.Uint64(buf[pos+8 : pos+16])
+		pos += 16
+	}
+	if len(buf) < pos+4 {
+		return 0, fmt.Errorf("mp4 box sidx is too short")
+	}
+	count := int(binary.BigEndian.Uint16(buf[pos+2 : pos+4]))
+	pos += 4
+	if len(buf) < pos+count*12 {
+		return 0, fmt.Errorf("mp4 box sidx is too short")
+	}
+	var referenced, duration uint64
+	for i := 0; i < count; i++ {
+		entry := buf[pos+i*12:]
+		referenced += uint64(binary.BigEndian.Uint32(entry[0:4]) & 0x7fffffff)
+		duration += uint64(binary.BigEndian.Uint32(entry[4:8]))
+	}
+	end := sidx.End() + int64(firstOffset) + int64(referenced)
+	if end > size {
+		return 0, ErrTruncated{Box: "sidx", End: end, Size: size}
+	}
+	if timescale == 0 {
+		return 0, nil
+	}
+	return scale(duration, timescale), nil
+}
@@ -0,0 +1,68 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func box(typ string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	b := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(b, uint32(8+len(body)))
+	copy(b[4:], typ)
+	return append(b, body...)
+}
+
+func u32(values ...uint32) []byte {
+	b := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint32(b[i*4:], v)
+	}
+	return b
+}
+
+func TestInspect(t *testing.T) {
+	// version/flags, creation time, modification time, timescale, duration
+	mvhd := box("mvhd", u32(0, 0, 0, 1000, 5000), make([]byte, 80))
+	file := bytes.Join([][]byte{
+		box("ftyp", []byte("isom"), u32(512)),
+		box("moov", mvhd),
+		box("mdat", make([]byte, 100)),
+	}, nil)
+
+	info, err := Inspect(bytes.NewReader(file), int64(len(file)))
+	assert.NoError(t, err)
+	assert.False(t, info.Fragmented)
+	assert.Equal(t, 5*time.Second, info.Duration)
+
+	_, err = Inspect(bytes.NewReader(file[:len(file)-10]), int64(len(file)-10))
+	assert.IsType(t, ErrTruncated{}, err)
+}
+
+func TestInspectFragmented(t *testing.T) {
+	mvhd := box("mvhd", u32(0, 0, 0, 1000, 0), make([]byte, 80))
+	fragment := bytes.Join([][]byte{box("moof", make([]byte, 16)), box("mdat", make([]byte, 40))}, nil)
+	// version/flags, reference id, timescale, earliest pts, first offset, reserved/count,
+	// then the reference size, subsegment duration and sap of the only reference
+	sidx := box("sidx", u32(0, 1, 1000, 0, 0, 1, uint32(len(fragment)), 3000, 0x90000000))
+	file := bytes.Join([][]byte{
+		box("ftyp", []byte("iso5"), u32(1)),
+		box("moov", mvhd, box("mvex", box("trex", make([]byte, 24)))),
+		sidx,
+		fragment,
+	}, nil)
+
+	info, err := Inspect(bytes.NewReader(file), int64(len(file)))
+	assert.NoError(t, err)
+	assert.True(t, info.Fragmented)
+	assert.Equal(t, 3*time.Second, info.Duration)
+
+	// the last fragment is missing
+	truncated := file[:len(file)-len(fragment)]
+	_, err = Inspect(bytes.NewReader(truncated), int64(len(truncated)))
+	assert.IsType(t, ErrTruncated{}, err)
+}
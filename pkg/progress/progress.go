@@ -0,0 +1,108 @@
+package progress
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventStarted  EventType = "started"
+	EventProgress EventType = "progress"
+	EventRetry    EventType = "retry"
+	EventMerged   EventType = "merged"
+	EventFinished EventType = "finished"
+	EventFailed   EventType = "failed"
+)
+
+// Event is one step of a download, fields not related to the type are left empty.
+type Event struct {
+	Type  EventType `json:"type"`
+	Time  time.Time `json:"time"`
+	Title string    `json:"title,omitempty"`
+	Path  string    `json:"path,omitempty"`
+	Bytes int64     `json:"bytes,omitempty"`
+	Total int64     `json:"total,omitempty"`
+	// Speed is in bytes per second.
+	Speed float64 `json:"speed,omitempty"`
+	// ETA is in seconds.
+	ETA     float64 `json:"eta,omitempty"`
+	Attempt int     `json:"attempt,omitempty"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// Listener receives the events of downloads, it may be called from many goroutines.
+type Listener interface {
+	OnEvent(event Event)
+}
+
+// ListenerFunc adapts a function to Listener.
+type ListenerFunc func(event Event)
+
+func (f ListenerFunc) OnEvent(event Event) {
+	f(event)
+}
+
+type jsonListener struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewJSONListener writes every event as one line of json into w.
+func NewJSONListener(w io.Writer) Listener {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return &jsonListener{encoder: encoder}
+}
+
+func (l *jsonListener) OnEvent(event Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_ = l.encoder.Encode(event)
+}
+
+// Interval is the least time between two progress events of a Reader.
+const Interval = 500 * time.Millisecond
+
+type reader struct {
+	reader   io.Reader
+	listener Listener
+	title    string
+	total    int64
+	bytes    int64
+	start    time.Time
+	last     time.Time
+	lastSize int64
+}
+
+// NewReader reports the progress of reading total bytes from r to listener, total <= 0 means unknown.
+func NewReader(r io.Reader, title string, total int64, listener Listener) io.Reader {
+	now := time.Now()
+	return &reader{reader: r, listener: listener, title: title, total: total, start: now, last: now}
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.bytes += int64(n)
+	now := time.Now()
+	if elapsed := now.Sub(r.last); elapsed >= Interval || (err == io.EOF && r.bytes != r.lastSize && elapsed > 0) {
+		event := Event{
+			Type:  EventProgress,
+			Time:  now,
+			Title: r.title,
+			Bytes: r.bytes,
+			Total: r.total,
+			Speed: float64(r.bytes-r.lastSize) / elapsed.Seconds(),
+		}
+		if average := float64(r.bytes) / now.Sub(r.start).Seconds(); r.total > 0 && average > 0 {
+			event.ETA = float64(r.total-r.bytes) / average
+		}
+		r.listener.OnEvent(event)
+		r.last = now
+		r.lastSize = r.bytes
+	}
+	return n, err
+}
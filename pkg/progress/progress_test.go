@@ -0,0 +1,31 @@
+package progress
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONListener(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	listener := NewJSONListener(buffer)
+	_, err := io.Copy(io.Discard, NewReader(bytes.NewReader(make([]byte, 4096)), "Video", 4096, listener))
+	assert.NoError(t, err)
+	listener.OnEvent(Event{Type: EventFinished, Title: "Video", Path: "video.mp4"})
+
+	events := make([]Event, 0)
+	scanner := bufio.NewScanner(buffer)
+	for scanner.Scan() {
+		event := Event{}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+	assert.Len(t, events, 2)
+	assert.Equal(t, EventProgress, events[0].Type)
+	assert.Equal(t, int64(4096), events[0].Bytes)
+	assert.Equal(t, EventFinished, events[1].Type)
+}
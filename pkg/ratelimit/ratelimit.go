@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket limiting the bytes per second, it can be shared by many readers.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   int64
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a limiter of rate bytes per second, a rate <= 0 means unlimited.
+func NewLimiter(rate int64) *Limiter {
+	return &Limiter{rate: rate, last: time.Now()}
+}
+
+// SetRate changes the rate, the readers sharing the limiter follow it from their next read.
+func (l *Limiter) SetRate(rate int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.tokens = 0
+	l.last = time.Now()
+}
+
+func (l *Limiter) Rate() int64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// burst is the bucket size, a tenth of the rate keeps the transfer smooth.
+func (l *Limiter) burst() int {
+	burst := l.rate / 10
+	if burst < 1024 {
+		burst = 1024
+	}
+	return int(burst)
+}
+
+// WaitN blocks until n bytes are allowed to pass.
+func (l *Limiter) WaitN(n int) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	if l.rate <= 0 {
+		l.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * float64(l.rate)
+	if max := float64(l.burst()); l.tokens > max {
+		l.tokens = max
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / float64(l.rate) * float64(time.Second))
+	}
+	l.mu.Unlock()
+	time.Sleep(wait)
+}
+
+type reader struct {
+	reader   io.Reader
+	limiters []*Limiter
+}
+
+// NewReader limits r by all the limiters, nil limiters are ignored.
+func NewReader(r io.Reader, limiters ...*Limiter) io.Reader {
+	active := make([]*Limiter, 0, len(limiters))
+	for _, l := range limiters {
+		if l != nil {
+			active = append(active, l)
+		}
+	}
+	if len(active) == 0 {
+		return r
+	}
+	return &reader{reader: r, limiters: active}
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	for _, l := range r.limiters {
+		l.mu.Lock()
+		if burst := l.burst(); l.rate > 0 && len(p) > burst {
+			p = p[:burst]
+		}
+		l.mu.Unlock()
+	}
+	n, err := r.reader.Read(p)
+	for _, l := range r.limiters {
+		l.WaitN(n)
+	}
+	return n, err
+}
+
+// ParseRate parses rates like 500K, 2M or 1.5m into bytes per second, an empty string or 0 is unlimited.
+func ParseRate(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return 0, nil
+	}
+	unit := int64(1)
+	switch strings.ToUpper(s[len(s)-1:]) {
+	case "K":
+		unit = 1 << 10
+	case "M":
+		unit = 1 << 20
+	case "G":
+		unit = 1 << 30
+	}
+	if unit != 1 {
+		s = s[:len(s)-1]
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid rate: %s", s)
+	}
+	return int64(value * float64(unit)), nil
+}
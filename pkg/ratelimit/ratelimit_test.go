@@ -0,0 +1,37 @@
+package ratelimit
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRate(t *testing.T) {
+	for s, expected := range map[string]int64{
+		"":     0,
+		"0":    0,
+		"512":  512,
+		"500K": 500 << 10,
+		"2M":   2 << 20,
+		"1.5m": 3 << 19,
+	} {
+		rate, err := ParseRate(s)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, rate, s)
+	}
+	_, err := ParseRate("fast")
+	assert.Error(t, err)
+}
+
+func TestReader(t *testing.T) {
+	limiter := NewLimiter(64 << 10)
+	start := time.Now()
+	n, err := io.Copy(io.Discard, NewReader(bytes.NewReader(make([]byte, 32<<10)), limiter))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(32<<10), n)
+	// 32K at 64K/s takes about half a second
+	assert.Greater(t, time.Since(start), 300*time.Millisecond)
+}
@@ -0,0 +1,114 @@
+package subtitle
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	FormatSRT = "srt"
+	FormatVTT = "vtt"
+	FormatASS = "ass"
+)
+
+var Formats = []string{FormatSRT, FormatVTT, FormatASS}
+
+// Cue is a line of the subtitle shown between From and To.
+type Cue struct {
+	From    time.Duration
+	To      time.Duration
+	Content string
+	// Top places the line at the top of the screen, only ASS keeps it.
+	Top bool
+}
+
+// ErrInvalidFormat is returned for a format not in Formats.
+type ErrInvalidFormat string
+
+func (err ErrInvalidFormat) Error() string {
+	return fmt.Sprintf("invalid subtitle format: %s", string(err))
+}
+
+// Write writes the cues in the format.
+func Write(w io.Writer, format string, cues []Cue) error {
+	switch format {
+	case FormatSRT:
+		return WriteSRT(w, cues)
+	case FormatVTT:
+		return WriteVTT(w, cues)
+	case FormatASS:
+		return WriteASS(w, cues)
+	default:
+		return ErrInvalidFormat(format)
+	}
+}
+
+func WriteSRT(w io.Writer, cues []Cue) error {
+	bw := bufio.NewWriter(w)
+	for i, cue := range cues {
+		fmt.Fprintf(bw, "%d\n%s --> %s\n%s\n\n", i+1, timestamp(cue.From, ","), timestamp(cue.To, ","), cue.Content)
+	}
+	return bw.Flush()
+}
+
+func WriteVTT(w io.Writer, cues []Cue) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("WEBVTT\n\n")
+	for _, cue := range cues {
+		// a blank line ends the cue and --> is reserved
+		content := strings.ReplaceAll(strings.ReplaceAll(cue.Content, "\n\n", "\n"), "-->", "->")
+		fmt.Fprintf(bw, "%s --> %s\n%s\n\n", timestamp(cue.From, "."), timestamp(cue.To, "."), content)
+	}
+	return bw.Flush()
+}
+
+const assHeader = `[Script Info]
+ScriptType: v4.00+
+PlayResX: 1920
+PlayResY: 1080
+WrapStyle: 0
+ScaledBorderAndShadow: yes
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Default,sans-serif,64,&H00FFFFFF,&H00FFFFFF,&H00000000,&H80000000,0,0,0,0,100,100,0,0,1,3,0,2,40,40,40,1
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+`
+
+var assEscaper = strings.NewReplacer("\r\n", `\N`, "\n", `\N`, "{", "｛", "}", "｝")
+
+func WriteASS(w io.Writer, cues []Cue) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(assHeader)
+	for _, cue := range cues {
+		text := assEscaper.Replace(cue.Content)
+		if cue.Top {
+			text = `{\an8}` + text
+		}
+		fmt.Fprintf(bw, "Dialogue: 0,%s,%s,Default,,0,0,0,,%s\n", assTimestamp(cue.From), assTimestamp(cue.To), text)
+	}
+	return bw.Flush()
+}
+
+// timestamp formats d as hh:mm:ss followed by sep and the milliseconds.
+func timestamp(d time.Duration, sep string) string {
+	if d < 0 {
+		d = 0
+	}
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+// assTimestamp formats d as h:mm:ss.cc.
+func assTimestamp(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	cs := d.Milliseconds() / 10
+	return fmt.Sprintf("%d:%02d:%02d.%02d", cs/360000, cs/6000%60, cs/100%60, cs%100)
+}
@@ -0,0 +1,39 @@
+package subtitle
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var cues = []Cue{
+	{From: 1500 * time.Millisecond, To: 3 * time.Second, Content: "你好"},
+	{From: time.Hour + 2*time.Minute + 3*time.Second + 45*time.Millisecond, To: time.Hour + 2*time.Minute + 5*time.Second, Content: "第一行\n{第二行}", Top: true},
+}
+
+func TestWriteSRT(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, Write(&buf, FormatSRT, cues))
+	assert.Equal(t, "1\n00:00:01,500 --> 00:00:03,000\n你好\n\n"+
+		"2\n01:02:03,045 --> 01:02:05,000\n第一行\n{第二行}\n\n", buf.String())
+}
+
+func TestWriteVTT(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, Write(&buf, FormatVTT, cues))
+	assert.Equal(t, "WEBVTT\n\n00:00:01.500 --> 00:00:03.000\n你好\n\n"+
+		"01:02:03.045 --> 01:02:05.000\n第一行\n{第二行}\n\n", buf.String())
+}
+
+func TestWriteASS(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, Write(&buf, FormatASS, cues))
+	assert.Contains(t, buf.String(), "Dialogue: 0,0:00:01.50,0:00:03.00,Default,,0,0,0,,你好\n")
+	assert.Contains(t, buf.String(), "Dialogue: 0,1:02:03.04,1:02:05.00,Default,,0,0,0,,{\\an8}第一行\\N｛第二行｝\n")
+}
+
+func TestWriteInvalidFormat(t *testing.T) {
+	assert.Equal(t, ErrInvalidFormat("txt"), Write(&bytes.Buffer{}, "txt", cues))
+}
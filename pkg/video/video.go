@@ -55,6 +55,12 @@ var ssIDRegexpList = []*regexp.Regexp{
 	regexp.MustCompile(`([0-9])+`),
 }
 
+// auIDRegexp matches the songs of the music area like au1234 or https://www.bilibili.com/audio/au1234.
+var auIDRegexp = regexp.MustCompile(`(?i)(?:^|audio/)au([0-9]+)`)
+
+// amIDRegexp matches the audio menus like am1234 or https://www.bilibili.com/audio/am1234.
+var amIDRegexp = regexp.MustCompile(`(?i)(?:^|audio/)am([0-9]+)`)
+
 var epIDRegexpList = []*regexp.Regexp{
 	regexp.MustCompile(`https?:\/\/(www\.)?[-a-zA-Z0-9@:%._\+~#=]{1,256}\.[a-zA-Z0-9()]{1,6}\b([-a-zA-Z0-9()@:%_\+.~#?&//=]*)`),
 	regexp.MustCompile(`(play\/ep([0-9])+)`),
@@ -103,3 +109,29 @@ func IsSSID(id string) bool {
 func IsEpID(id string) bool {
 	return regexp.MustCompile(`(play\/ep([0-9])+)`).MatchString(id)
 }
+
+func IsAuID(id string) bool {
+	return auIDRegexp.MatchString(id)
+}
+
+func IsAmID(id string) bool {
+	return amIDRegexp.MatchString(id)
+}
+
+// ExtractAuID returns the song id of au1234 or its url.
+func ExtractAuID(id string) (int64, error) {
+	return extractAudioID(auIDRegexp, id)
+}
+
+// ExtractAmID returns the menu id of am1234 or its url.
+func ExtractAmID(id string) (int64, error) {
+	return extractAudioID(amIDRegexp, id)
+}
+
+func extractAudioID(re *regexp.Regexp, id string) (int64, error) {
+	subs := re.FindStringSubmatch(id)
+	if subs == nil {
+		return 0, fmt.Errorf("invalid audio id: %s", id)
+	}
+	return strconv.ParseInt(subs[1], 10, 64)
+}
@@ -51,3 +51,20 @@ func TestExtractSSID(t *testing.T) {
 	}
 	t.Log(id)
 }
+
+func TestExtractAudioID(t *testing.T) {
+	for _, id := range []string{"au1234", "AU1234", "https://www.bilibili.com/audio/au1234?type=3", "https://m.bilibili.com/audio/au1234"} {
+		assert.True(t, IsAuID(id))
+		assert.False(t, IsAmID(id))
+		auID, err := ExtractAuID(id)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1234), auID)
+	}
+	amID, err := ExtractAmID("https://www.bilibili.com/audio/am10624")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10624), amID)
+	assert.False(t, IsAuID("https://www.bilibili.com/video/BV1sy4y197KP/?spm_id_from=333.audio"))
+	assert.False(t, IsAuID("BV1au411c7mD"))
+	_, err = ExtractAuID("BV1kd4y1W7RG")
+	assert.Error(t, err)
+}